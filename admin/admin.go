@@ -0,0 +1,506 @@
+package admin
+
+import (
+	"code-utils-demos/cache"
+	cache_go "code-utils-demos/cachev2.0"
+	"code-utils-demos/metrics"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 用于调试的管理接口：查看metrics.Registry中注册的cache和table 并可删除entry、清空、调整容量
+// 所有响应均为json 出错时返回 {"error": "..."}
+//
+// 重要：未设置Options.Token时接口只读 所有POST(删除、清空、调整容量)均返回403
+// 需要修改时必须设置Token 且只应在可信的网络中开放
+//
+//	GET  /                                  所有cache和table的统计信息
+//	GET  /caches/{name}?offset=&limit=      cache的统计信息及一页keys(按最近使用排序)
+//	GET  /caches/{name}/entries/{key}       entry的元数据
+//	POST /caches/{name}/erase?key=          删除entry
+//	POST /caches/{name}/clear               清空cache
+//	POST /caches/{name}/capacity?capacity=  调整容量
+//	GET  /tables/{name}?offset=&limit=      table的item个数及一页keys(按key排序)
+//	GET  /tables/{name}/entries/{key}       item的元数据
+//	POST /tables/{name}/delete?key=         删除item
+//	POST /tables/{name}/flush               清空table
+//
+// 挂载在子路径下时需要去掉前缀 例如：
+//
+//	http.Handle("/debug/cache/", http.StripPrefix("/debug/cache", admin.Handler(metrics.Default, admin.Options{Token: token})))
+//
+// 注：keys及entry操作只支持key为string的cache(如LRUCache[string, V]、ShardedLRUCache) 其他cache只能查看统计信息、清空及调整容量
+// table的key为interface{} 按fmt.Sprint(key)匹配
+
+// 每页keys的默认个数及最大个数
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+type Options struct {
+	// 非空时所有请求都必须携带token：Authorization: Bearer <token> 或 X-Admin-Token: <token>
+	// 为空时不校验token 但拒绝所有修改类的请求
+	Token string
+}
+
+// 可分页查看keys及entry的cache
+type keyedCache interface {
+	Keys() []string
+	Entry(key string) (cache.EntryInfo, bool)
+	Erase(key string)
+}
+
+type clearer interface {
+	Clear()
+}
+
+type capacitySetter interface {
+	SetCapacity(capacity int64) error
+}
+
+// 可管理的table：cache_go.CacheTable
+type table interface {
+	Foreach(trans func(key interface{}, item *cache_go.CacheItem))
+	Delete(key interface{}) (*cache_go.CacheItem, error)
+	Flush()
+}
+
+type handler struct {
+	reg   *metrics.Registry
+	token string
+}
+
+// 创建管理接口 reg为nil时使用metrics.Default
+func Handler(reg *metrics.Registry, opts Options) http.Handler {
+	if reg == nil {
+		reg = metrics.Default
+	}
+	return &handler{reg: reg, token: opts.Token}
+}
+
+type route struct {
+	method string
+	fn     func(h *handler, w http.ResponseWriter, req *http.Request, name, key string)
+}
+
+// 路径为/{kind}/{name}[/{action}[/{key}]] 按kind/action查找
+// 注：不使用ServeMux的method及通配符模式 其是否生效取决于main module的go版本(GODEBUG httpmuxgo121)
+var routes = map[string]route{
+	"caches/":         {http.MethodGet, (*handler).cache},
+	"caches/entries":  {http.MethodGet, (*handler).cacheEntry},
+	"caches/erase":    {http.MethodPost, (*handler).cacheErase},
+	"caches/clear":    {http.MethodPost, (*handler).cacheClear},
+	"caches/capacity": {http.MethodPost, (*handler).cacheCapacity},
+	"tables/":         {http.MethodGet, (*handler).table},
+	"tables/entries":  {http.MethodGet, (*handler).tableEntry},
+	"tables/delete":   {http.MethodPost, (*handler).tableDelete},
+	"tables/flush":    {http.MethodPost, (*handler).tableFlush},
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !h.authorized(req) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="cache admin"`)
+		writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+		return
+	}
+
+	r, name, key, err := match(req.URL.EscapedPath())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if r.fn == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint: %s", req.URL.Path))
+		return
+	}
+	if req.Method != r.method && !(r.method == http.MethodGet && req.Method == http.MethodHead) {
+		w.Header().Set("Allow", r.method)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+	if r.method != http.MethodGet && h.token == "" {
+		writeError(w, http.StatusForbidden, errors.New("mutating endpoints are disabled without Options.Token"))
+		return
+	}
+	r.fn(h, w, req, name, key)
+}
+
+// 按转义后的路径切分：name、key中可以包含编码后的"/" key中也可以直接包含"/"
+// 没有匹配的路径时返回的route.fn为nil
+func match(path string) (r route, name, key string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 4)
+	if len(parts) == 1 && parts[0] == "" {
+		return route{http.MethodGet, (*handler).index}, "", "", nil
+	}
+	if len(parts) < 2 || parts[1] == "" {
+		return
+	}
+
+	action := ""
+	if len(parts) > 2 {
+		action = parts[2]
+	}
+	hasKey := len(parts) == 4 && parts[3] != ""
+	r, ok := routes[parts[0]+"/"+action]
+	if !ok || (action == "entries") != hasKey {
+		return route{}, "", "", nil
+	}
+
+	if name, err = url.PathUnescape(parts[1]); err != nil {
+		return route{}, "", "", err
+	}
+	if hasKey {
+		if key, err = url.PathUnescape(parts[3]); err != nil {
+			return route{}, "", "", err
+		}
+	}
+	return r, name, key, nil
+}
+
+func (h *handler) authorized(req *http.Request) bool {
+	if h.token == "" {
+		return true
+	}
+	token := req.Header.Get("X-Admin-Token")
+	if auth := req.Header.Get("Authorization"); token == "" && strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// ========================================统计信息=====================================
+
+type cacheStats struct {
+	cache.StatsSnapshot
+	HitRatio float64
+}
+
+func newCacheStats(c metrics.CacheSource) cacheStats {
+	s := c.StatsSnapshot()
+	return cacheStats{s, s.HitRatio()}
+}
+
+func (h *handler) index(w http.ResponseWriter, req *http.Request, _, _ string) {
+	caches, tables := h.reg.Names()
+
+	resp := struct {
+		Caches map[string]cacheStats
+		Tables map[string]int
+	}{
+		Caches: make(map[string]cacheStats, len(caches)),
+		Tables: make(map[string]int, len(tables)),
+	}
+	for _, name := range caches {
+		if c, ok := h.reg.Cache(name); ok { // 可能已被注销
+			resp.Caches[name] = newCacheStats(c)
+		}
+	}
+	for _, name := range tables {
+		if t, ok := h.reg.Table(name); ok {
+			resp.Tables[name] = t.Count()
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// 一页keys
+type page struct {
+	Offset int
+	Limit  int
+	Total  int
+	Keys   []string
+}
+
+func newPage(keys []string, offset, limit int) page {
+	p := page{Offset: offset, Limit: limit, Total: len(keys), Keys: []string{}}
+	if offset < len(keys) {
+		p.Keys = keys[offset:min(offset+limit, len(keys))]
+	}
+	return p
+}
+
+// 解析offset、limit
+func pageParams(req *http.Request) (offset, limit int, err error) {
+	limit = DefaultPageSize
+	q := req.URL.Query()
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			return 0, 0, fmt.Errorf("invalid limit %q", v)
+		}
+		limit = min(limit, MaxPageSize)
+	}
+	return offset, limit, nil
+}
+
+// ========================================cache=====================================
+
+func (h *handler) lookupCache(w http.ResponseWriter, name string) (metrics.CacheSource, bool) {
+	c, ok := h.reg.Cache(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("cache %q not found", name))
+	}
+	return c, ok
+}
+
+func (h *handler) lookupKeyedCache(w http.ResponseWriter, name string) (keyedCache, bool) {
+	c, ok := h.lookupCache(w, name)
+	if !ok {
+		return nil, false
+	}
+	kc, ok := c.(keyedCache)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("cache %q does not support string keys", name))
+	}
+	return kc, ok
+}
+
+func (h *handler) cache(w http.ResponseWriter, req *http.Request, name, _ string) {
+	c, ok := h.lookupCache(w, name)
+	if !ok {
+		return
+	}
+	offset, limit, err := pageParams(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	resp := struct {
+		Name  string
+		Stats cacheStats
+		Keys  *page `json:",omitempty"` // 不支持string key的cache没有keys
+	}{Name: name, Stats: newCacheStats(c)}
+	if kc, ok := c.(keyedCache); ok {
+		p := newPage(kc.Keys(), offset, limit)
+		resp.Keys = &p
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) cacheEntry(w http.ResponseWriter, req *http.Request, name, key string) {
+	c, ok := h.lookupKeyedCache(w, name)
+	if !ok {
+		return
+	}
+	info, ok := c.Entry(key)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("key %q not found", key))
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (h *handler) cacheErase(w http.ResponseWriter, req *http.Request, name, _ string) {
+	c, ok := h.lookupKeyedCache(w, name)
+	if !ok {
+		return
+	}
+	key, ok := requiredParam(w, req, "key")
+	if !ok {
+		return
+	}
+	if _, ok := c.Entry(key); !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("key %q not found", key))
+		return
+	}
+	c.Erase(key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) cacheClear(w http.ResponseWriter, req *http.Request, name, _ string) {
+	c, ok := h.lookupCache(w, name)
+	if !ok {
+		return
+	}
+	cc, ok := c.(clearer)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("cache %q does not support Clear", name))
+		return
+	}
+	cc.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 返回调整后的统计信息
+func (h *handler) cacheCapacity(w http.ResponseWriter, req *http.Request, name, _ string) {
+	c, ok := h.lookupCache(w, name)
+	if !ok {
+		return
+	}
+	cs, ok := c.(capacitySetter)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("cache %q does not support SetCapacity", name))
+		return
+	}
+	v, ok := requiredParam(w, req, "capacity")
+	if !ok {
+		return
+	}
+	capacity, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid capacity %q", v))
+		return
+	}
+	if err := cs.SetCapacity(capacity); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newCacheStats(c))
+}
+
+// ========================================table=====================================
+
+// table中item的元数据
+type tableEntry struct {
+	Key         string
+	CreatedOn   time.Time
+	AccessedOn  time.Time
+	AccessCount int64
+	LifeSpan    time.Duration // 0表示不过期
+}
+
+func (h *handler) lookupTable(w http.ResponseWriter, name string) (metrics.TableSource, table, bool) {
+	src, ok := h.reg.Table(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("table %q not found", name))
+		return nil, nil, false
+	}
+	t, ok := src.(table)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("table %q does not support inspection", name))
+	}
+	return src, t, ok
+}
+
+// 按fmt.Sprint(key)查找item
+func findItem(t table, key string) (k interface{}, item *cache_go.CacheItem) {
+	t.Foreach(func(key2 interface{}, item2 *cache_go.CacheItem) {
+		if item == nil && fmt.Sprint(key2) == key {
+			k, item = key2, item2
+		}
+	})
+	return
+}
+
+func (h *handler) table(w http.ResponseWriter, req *http.Request, name, _ string) {
+	src, t, ok := h.lookupTable(w, name)
+	if !ok {
+		return
+	}
+	offset, limit, err := pageParams(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var keys []string
+	t.Foreach(func(key interface{}, item *cache_go.CacheItem) {
+		keys = append(keys, fmt.Sprint(key))
+	})
+	sort.Strings(keys) // map的遍历顺序不固定 排序后分页才有意义
+
+	writeJSON(w, http.StatusOK, struct {
+		Name  string
+		Count int
+		Keys  page
+	}{name, src.Count(), newPage(keys, offset, limit)})
+}
+
+func (h *handler) tableEntry(w http.ResponseWriter, req *http.Request, name, key string) {
+	_, t, ok := h.lookupTable(w, name)
+	if !ok {
+		return
+	}
+	_, item := findItem(t, key)
+	if item == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("key %q not found", key))
+		return
+	}
+	writeJSON(w, http.StatusOK, tableEntry{
+		Key:         key,
+		CreatedOn:   item.CreatedOn(),
+		AccessedOn:  item.AccessedOn(),
+		AccessCount: item.AccessCount(),
+		LifeSpan:    item.LifeSpan(),
+	})
+}
+
+func (h *handler) tableDelete(w http.ResponseWriter, req *http.Request, name, _ string) {
+	_, t, ok := h.lookupTable(w, name)
+	if !ok {
+		return
+	}
+	key, ok := requiredParam(w, req, "key")
+	if !ok {
+		return
+	}
+	// 在Foreach之外删除：Foreach持有table的读锁
+	k, item := findItem(t, key)
+	if item == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("key %q not found", key))
+		return
+	}
+	if _, err := t.Delete(k); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, cache_go.ErrKeyNotFound) { // 已被并发删除或过期
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) tableFlush(w http.ResponseWriter, req *http.Request, name, _ string) {
+	_, t, ok := h.lookupTable(w, name)
+	if !ok {
+		return
+	}
+	t.Flush()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ========================================响应=====================================
+
+func requiredParam(w http.ResponseWriter, req *http.Request, name string) (string, bool) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing parameter %q", name))
+		return "", false
+	}
+	return v, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	b, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
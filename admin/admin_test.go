@@ -0,0 +1,348 @@
+package admin
+
+import (
+	"code-utils-demos/cache"
+	cache_go "code-utils-demos/cachev2.0"
+	"code-utils-demos/metrics"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "secret"
+
+type testEnv struct {
+	handler http.Handler
+	cache   *cache.LRUCache[string, string]
+	table   *cache_go.CacheTable
+}
+
+// 注册cache "c"(keys k0..k4)、"a/b"(key x/y)、"ints"(key不是string) 及table "t"(keys a..e)
+func newTestEnv(t *testing.T, token string) *testEnv {
+	t.Helper()
+	reg := metrics.NewRegistry()
+
+	c, err := cache.NewLRUCache[string, string](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	for i := 0; i < 5; i++ {
+		c.Set(fmt.Sprintf("k%d", i), "v", 1)
+	}
+
+	slash, _ := cache.NewLRUCache[string, string](10)
+	t.Cleanup(func() { slash.Close() })
+	slash.Set("x/y", "v", 1)
+
+	ints, _ := cache.NewLRUCache[int, int](10)
+	t.Cleanup(func() { ints.Close() })
+	ints.Set(1, 1, 1)
+
+	tbl := cache_go.Cache("admin-test-" + t.Name()) // table按名字全局共享
+	tbl.Flush()
+	t.Cleanup(tbl.Flush)
+	for _, k := range []string{"c", "a", "e", "b", "d"} {
+		tbl.Add(k, 0, "v")
+	}
+
+	for name, src := range map[string]metrics.CacheSource{"c": c, "a/b": slash, "ints": ints} {
+		if err := reg.RegisterCache(name, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := reg.RegisterTable("t", tbl); err != nil {
+		t.Fatal(err)
+	}
+	return &testEnv{Handler(reg, Options{Token: token}), c, tbl}
+}
+
+// 携带token发送请求
+func (e *testEnv) do(method, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	w := httptest.NewRecorder()
+	e.handler.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+}
+
+func TestRouting(t *testing.T) {
+	e := newTestEnv(t, testToken)
+	for _, tt := range []struct {
+		method, target string
+		status         int
+	}{
+		{"GET", "/", http.StatusOK},
+		{"HEAD", "/", http.StatusOK},
+		{"GET", "/caches/c", http.StatusOK},
+		{"GET", "/caches/c/", http.StatusOK},
+		{"GET", "/caches/a%2Fb", http.StatusOK}, // name中编码后的"/"
+		{"GET", "/caches/a%2Fb/entries/x/y", http.StatusOK},
+		{"GET", "/caches/a%2Fb/entries/x%2Fy", http.StatusOK},
+		{"GET", "/caches/c/entries/k1", http.StatusOK},
+		{"GET", "/caches/c/entries/missing", http.StatusNotFound},
+		{"GET", "/caches/c/entries", http.StatusNotFound}, // 缺少key
+		{"GET", "/caches/missing", http.StatusNotFound},
+		{"GET", "/caches/ints", http.StatusOK},
+		{"GET", "/caches/ints/entries/1", http.StatusNotImplemented},
+		{"GET", "/caches/c/unknown", http.StatusNotFound},
+		{"GET", "/caches", http.StatusNotFound},
+		{"GET", "/caches/", http.StatusNotFound},
+		{"GET", "/unknown/c", http.StatusNotFound},
+		{"GET", "/tables/t", http.StatusOK},
+		{"GET", "/tables/t/entries/a", http.StatusOK},
+		{"GET", "/tables/t/entries/z", http.StatusNotFound},
+		{"GET", "/tables/missing", http.StatusNotFound},
+	} {
+		w := e.do(tt.method, tt.target)
+		if w.Code != tt.status {
+			t.Errorf("%s %s = %d %s, want %d", tt.method, tt.target, w.Code, w.Body, tt.status)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s %s Content-Type = %q", tt.method, tt.target, ct)
+		}
+	}
+
+	var index struct {
+		Caches map[string]cache.StatsSnapshot
+		Tables map[string]int
+	}
+	decode(t, e.do("GET", "/"), &index)
+	if len(index.Caches) != 3 || index.Caches["c"].Length != 5 || index.Tables["t"] != 5 {
+		t.Errorf("index = %+v", index)
+	}
+
+	var info cache.EntryInfo
+	decode(t, e.do("GET", "/caches/c/entries/k1"), &info)
+	if info.Key != "k1" || info.Size != 1 {
+		t.Errorf("entry = %+v", info)
+	}
+
+	var ints map[string]json.RawMessage
+	decode(t, e.do("GET", "/caches/ints"), &ints)
+	if _, ok := ints["Keys"]; ok {
+		t.Error("cache without string keys listed keys")
+	}
+}
+
+func TestAuth(t *testing.T) {
+	e := newTestEnv(t, testToken)
+	for _, tt := range []struct {
+		name, header, value string
+		status              int
+	}{
+		{"bearer", "Authorization", "Bearer " + testToken, http.StatusOK},
+		{"x-admin-token", "X-Admin-Token", testToken, http.StatusOK},
+		{"missing", "", "", http.StatusUnauthorized},
+		{"wrong bearer", "Authorization", "Bearer wrong", http.StatusUnauthorized},
+		{"wrong x-admin-token", "X-Admin-Token", "wrong", http.StatusUnauthorized},
+		{"no bearer scheme", "Authorization", testToken, http.StatusUnauthorized},
+		{"basic scheme", "Authorization", "Basic " + testToken, http.StatusUnauthorized},
+		{"prefix of token", "X-Admin-Token", testToken[:3], http.StatusUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, r := range []struct{ method, target string }{{"GET", "/caches/c"}, {"POST", "/caches/c/clear"}} {
+				req := httptest.NewRequest(r.method, r.target, nil)
+				if tt.header != "" {
+					req.Header.Set(tt.header, tt.value)
+				}
+				w := httptest.NewRecorder()
+				e.handler.ServeHTTP(w, req)
+
+				want := tt.status
+				if want == http.StatusOK && r.method == "POST" {
+					want = http.StatusNoContent
+				}
+				if w.Code != want {
+					t.Errorf("%s %s = %d, want %d", r.method, r.target, w.Code, want)
+				}
+				if want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+					t.Error("401 without WWW-Authenticate")
+				}
+			}
+		})
+	}
+}
+
+// 未设置token时只读
+func TestNoTokenReadOnly(t *testing.T) {
+	e := newTestEnv(t, "")
+	if w := e.do("GET", "/caches/c"); w.Code != http.StatusOK {
+		t.Fatalf("GET without token = %d, want 200", w.Code)
+	}
+	for _, target := range []string{
+		"/caches/c/erase?key=k1",
+		"/caches/c/clear",
+		"/caches/c/capacity?capacity=1",
+		"/tables/t/delete?key=a",
+		"/tables/t/flush",
+	} {
+		if w := e.do("POST", target); w.Code != http.StatusForbidden {
+			t.Errorf("POST %s without token = %d, want 403", target, w.Code)
+		}
+	}
+	if s := e.cache.StatsSnapshot(); s.Length != 5 || s.Capacity != 100 {
+		t.Errorf("cache modified: %+v", s)
+	}
+	if n := e.table.Count(); n != 5 {
+		t.Errorf("table modified: Count = %d", n)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	e := newTestEnv(t, testToken)
+	keys := e.cache.Keys() // 按最近使用排序
+	for _, tt := range []struct {
+		query         string
+		offset, limit int
+		keys          []string
+	}{
+		{"", 0, DefaultPageSize, keys},
+		{"?offset=0&limit=2", 0, 2, keys[:2]},
+		{"?offset=2&limit=2", 2, 2, keys[2:4]},
+		{"?offset=4&limit=2", 4, 2, keys[4:]},
+		{"?offset=5", 5, DefaultPageSize, []string{}},
+		{"?offset=100&limit=1", 100, 1, []string{}},
+		{"?limit=1", 0, 1, keys[:1]},
+		{"?limit=5000", 0, MaxPageSize, keys},
+	} {
+		var resp struct{ Keys page }
+		w := e.do("GET", "/caches/c"+tt.query)
+		if w.Code != http.StatusOK {
+			t.Errorf("GET /caches/c%s = %d", tt.query, w.Code)
+			continue
+		}
+		decode(t, w, &resp)
+		p := resp.Keys
+		if p.Offset != tt.offset || p.Limit != tt.limit || p.Total != len(keys) || strings.Join(p.Keys, ",") != strings.Join(tt.keys, ",") || p.Keys == nil {
+			t.Errorf("GET /caches/c%s = %+v, want offset %d, limit %d, keys %v", tt.query, p, tt.offset, tt.limit, tt.keys)
+		}
+	}
+
+	for _, query := range []string{"?offset=-1", "?offset=x", "?limit=0", "?limit=-1", "?limit=x"} {
+		if w := e.do("GET", "/caches/c"+query); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /caches/c%s = %d, want 400", query, w.Code)
+		}
+		if w := e.do("GET", "/tables/t"+query); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /tables/t%s = %d, want 400", query, w.Code)
+		}
+	}
+
+	// table按key排序
+	var resp struct {
+		Count int
+		Keys  page
+	}
+	decode(t, e.do("GET", "/tables/t?offset=1&limit=3"), &resp)
+	if resp.Count != 5 || resp.Keys.Total != 5 || strings.Join(resp.Keys.Keys, ",") != "b,c,d" {
+		t.Errorf("GET /tables/t?offset=1&limit=3 = %+v", resp)
+	}
+}
+
+func TestCacheActions(t *testing.T) {
+	e := newTestEnv(t, testToken)
+
+	if w := e.do("POST", "/caches/c/erase?key=k1"); w.Code != http.StatusNoContent {
+		t.Fatalf("erase = %d %s", w.Code, w.Body)
+	}
+	if _, ok := e.cache.Entry("k1"); ok {
+		t.Error("k1 not erased")
+	}
+	for target, status := range map[string]int{
+		"/caches/c/erase?key=k1":       http.StatusNotFound, // 已删除
+		"/caches/c/erase":              http.StatusBadRequest,
+		"/caches/missing/erase?key=k1": http.StatusNotFound,
+		"/caches/ints/erase?key=1":     http.StatusNotImplemented,
+	} {
+		if w := e.do("POST", target); w.Code != status {
+			t.Errorf("POST %s = %d, want %d", target, w.Code, status)
+		}
+	}
+
+	w := e.do("POST", "/caches/c/capacity?capacity=2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("capacity = %d %s", w.Code, w.Body)
+	}
+	var s cache.StatsSnapshot
+	decode(t, w, &s)
+	if s.Capacity != 2 || s.Length != 2 {
+		t.Errorf("stats after SetCapacity(2) = %+v", s)
+	}
+	for _, target := range []string{"/caches/c/capacity", "/caches/c/capacity?capacity=x", "/caches/c/capacity?capacity=0"} {
+		if w := e.do("POST", target); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s = %d, want 400", target, w.Code)
+		}
+	}
+
+	if w := e.do("POST", "/caches/c/clear"); w.Code != http.StatusNoContent {
+		t.Fatalf("clear = %d %s", w.Code, w.Body)
+	}
+	if s := e.cache.StatsSnapshot(); s.Length != 0 || s.Capacity != 2 {
+		t.Errorf("stats after clear = %+v", s)
+	}
+}
+
+func TestTableActions(t *testing.T) {
+	e := newTestEnv(t, testToken)
+
+	if w := e.do("POST", "/tables/t/delete?key=a"); w.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d %s", w.Code, w.Body)
+	}
+	if e.table.Exists("a") {
+		t.Error("a not deleted")
+	}
+	for target, status := range map[string]int{
+		"/tables/t/delete?key=a":       http.StatusNotFound,
+		"/tables/t/delete":             http.StatusBadRequest,
+		"/tables/missing/delete?key=b": http.StatusNotFound,
+	} {
+		if w := e.do("POST", target); w.Code != status {
+			t.Errorf("POST %s = %d, want %d", target, w.Code, status)
+		}
+	}
+
+	if w := e.do("POST", "/tables/t/flush"); w.Code != http.StatusNoContent {
+		t.Fatalf("flush = %d %s", w.Code, w.Body)
+	}
+	if n := e.table.Count(); n != 0 {
+		t.Errorf("Count after flush = %d", n)
+	}
+}
+
+// 修改类的路由只接受POST 查看类的路由只接受GET(及HEAD)
+func TestMethodNotAllowed(t *testing.T) {
+	e := newTestEnv(t, testToken)
+	for _, tt := range []struct{ method, target, allow string }{
+		{"GET", "/caches/c/erase?key=k1", "POST"},
+		{"GET", "/caches/c/clear", "POST"},
+		{"GET", "/caches/c/capacity?capacity=1", "POST"},
+		{"GET", "/tables/t/delete?key=a", "POST"},
+		{"GET", "/tables/t/flush", "POST"},
+		{"PUT", "/caches/c/clear", "POST"},
+		{"POST", "/", "GET"},
+		{"POST", "/caches/c", "GET"},
+		{"DELETE", "/caches/c/entries/k1", "GET"},
+		{"POST", "/tables/t", "GET"},
+	} {
+		w := e.do(tt.method, tt.target)
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s = %d, Allow %q, want 405, Allow %q", tt.method, tt.target, w.Code, w.Header().Get("Allow"), tt.allow)
+		}
+	}
+	if s := e.cache.StatsSnapshot(); s.Length != 5 || s.Capacity != 100 {
+		t.Errorf("cache modified: %+v", s)
+	}
+	if n := e.table.Count(); n != 5 {
+		t.Errorf("table modified: Count = %d", n)
+	}
+}
@@ -1,37 +1,44 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"code-utils-demos/cache"
 	"log"
 )
 
 func main() {
-	c := cache.NewLRUCache(100)
+	c, err := cache.NewLRUCache[string, string](100)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer c.Close()
 	//===========================简单操作:Set、 Value=========================
 	c.Set("key1", "value1", 1)
-	value1 := c.Value("key1").(string)
+	value1 := c.Value("key1")
 	fmt.Println("key1:", value1)
 
 	c.Set("key1","value11111",1)
-	value1 = c.Value("key1").(string)
+	value1 = c.Value("key1")
 	fmt.Println("key1: ", value1)
 
 	c.Set("key2", "value2", 1)
-	value2 := c.Value("key2", "null").(string)
+	value2 := c.Value("key2", "null")
 	fmt.Println("key2:", value2)
 
-	value3 := c.Value("key3", "null").(string)
+	value3 := c.Value("key3", "null")
 	fmt.Println("key3:", value3)
 
-	value4 := c.Value("key4") // value4 is nil
+	value4 := c.Value("key4") // value4 is ""
 	fmt.Println("key4:", value4)
 
 	fmt.Println("==============================Done==========================")
 
 	//===========================简单操作：newId、Insert、Lookup、Erase、=========================
-	cc := cache.NewLRUCache(10)
+	cc, err := cache.NewLRUCache[string, interface{}](10)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer cc.Close()
 	// 创建new id
 	id0 := cc.NewId()
@@ -43,9 +50,10 @@ func main() {
 
 	// insert
 	v1 := "data:123"
-	h1 := cc.Insert("123", "data:123", len("data:123"), func(key string, value interface{}) {
+	h1, err := cc.Insert("123", "data:123", len("data:123"), func(key string, value interface{}) {
 		fmt.Printf("deleter(%q:%q)\n", key, value)
 	})
+	assert(err == nil, err)
 
 	// lookup
 	v2, h2, ok := cc.Lookup("123")
@@ -76,9 +84,10 @@ func main() {
 	fmt.Println("invoke deleter(123) end")
 
 	// insert
-	h4 := cc.Insert("abc", "data:abc", len("data:abc"), func(key string, value interface{}) {
+	h4, err := cc.Insert("abc", "data:abc", len("data:abc"), func(key string, value interface{}) {
 		fmt.Printf("deleter(%q:%q)\n", key, value)
 	})
+	assert(err == nil, err)
 	// release h4
 	// 此处虽然释放了handle 但是cache仍持有该key的handle，默认情况下新建的key对应的handle refs = 2 具体原因见lru.go
 	h4.Close()
@@ -95,14 +104,44 @@ func main() {
 	// this will cause the capacity(10) overflow, so the h4 deleter will be invoked
 	// 以下代码会触发h4 deleter: 由于当前的cache size > capacity 导致会触发压缩行为  淘汰时间相对久的key 故而会引发h4 deleter
 	fmt.Println("invoke deleter(h4) begin")
-	h5 := cc.Insert("456", "data:456", len("data:456"), func(key string, value interface{}) {
+	h5, err := cc.Insert("456", "data:456", len("data:456"), func(key string, value interface{}) {
 		fmt.Printf("deleter(%q:%q)\n", key, value)
 	})
+	assert(err == nil, err)
 	fmt.Println("invoke deleter(h4) end")
 
 	// 释放所有的handle
 	h5.Close()
 
+	// 非法的插入返回错误 而不是panic
+	_, err = cc.Insert("", "data", 4, nil)
+	assert(errors.Is(err, cache.ErrEmptyKey), err)
+	_, err = cc.Insert("789", "data:789", -1, nil)
+	assert(errors.Is(err, cache.ErrInvalidSize), err)
+	_, err = cc.Insert("789", "data:789:too-large", len("data:789:too-large"), nil)
+	assert(errors.Is(err, cache.ErrEntryTooLarge), err)
+
+	// size为0时自动计算：实现了cache.Sizer的value使用其Size() 否则估算其占用的内存(包括string、slice等的头部)
+	// "data:789"估算的size(string头部 + 8字节)超过了capacity(10)
+	fmt.Println("SizeOf(data:789):", cache.SizeOf("data:789"))
+	_, err = cc.Insert("789", "data:789", 0, nil)
+	assert(errors.Is(err, cache.ErrEntryTooLarge), err)
+
+	// 命名空间：不同id的视图中相同的key互不影响
+	ns1, err := cc.Namespace(id1)
+	assert(err == nil, err)
+	ns2, err := cc.Namespace(id2)
+	assert(err == nil, err)
+	h6, err := ns1.Insert("k", "ns1", 1, nil)
+	assert(err == nil, err)
+	h6.Close()
+	_, _, ok = ns2.Lookup("k")
+	assert(!ok)
+	// 使id1的命名空间失效：旧的entry立即未命中 之后由淘汰回收
+	cc.InvalidateNamespace(id1)
+	_, _, ok = ns1.Lookup("k")
+	assert(!ok)
+
 	// 统计
 	fmt.Println("StatsJSON:", cc.StatsJSON())
 
@@ -110,13 +149,19 @@ func main() {
 	fmt.Println("========================== Done 2.0 ===========================")
 
 	// ========================================= 简单操作：LRUHandle========================================
-	ccc := cache.NewLRUCache(100)
+	ccc, err := cache.NewLRUCache[string, interface{}](100)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer ccc.Close()
 
-	h11 := ccc.Insert("100", "101", 1, func(key string, value interface{}) {
+	h11, err := ccc.Insert("100", "101", 1, func(key string, value interface{}) {
 		fmt.Printf("deleter(%q, %q)\n", key, value.(string))
 	})
-	v11 := h11.(*cache.LRUHandle).Value().(string)
+	if err != nil {
+		log.Fatal(err)
+	}
+	v11 := h11.(*cache.LRUHandle[string, interface{}]).Value().(string)
 	fmt.Printf("v1: %s\n", v11)
 	h11.Close()
 
@@ -128,7 +173,7 @@ func main() {
 
 	// h2 still valid after Erase
 	ccc.Erase("100")
-	v22 := h22.(*cache.LRUHandle).Value().(string)
+	v22 := h22.(*cache.LRUHandle[string, interface{}]).Value().(string)
 	fmt.Printf("v2: %s\n", v22)
 
 	// but new lookup will failed
@@ -0,0 +1,544 @@
+package cache
+
+import (
+	"code-utils-demos/common"
+	"container/list"
+	"encoding/json"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ARCCache[string, interface{}]即可直接作为Cache接口使用
+var _ NamespacedCache = (*ARCCache[string, interface{}])(nil)
+
+// ARC(Adaptive Replacement Cache)
+// T1: 只被访问过一次的entry   T2: 被访问过至少两次的entry
+// B1/B2: 分别记录最近从T1/T2淘汰的key(ghost，只保存key和size 不保存value)
+// 命中B1说明T1过小 命中B2说明T2过小：据此自适应调整T1的目标大小p
+// 与LRUCache一样 容量按照entry的size计算
+// See https://www.usenix.org/legacy/events/fast03/tech/full_papers/megiddo/megiddo.pdf
+type ARCCache[K comparable, V any] struct {
+	*_ARCCache[K, V]
+}
+
+type _ARCCache[K comparable, V any] struct {
+	mu sync.Mutex
+
+	t1, t2 *list.List // 存放*ARCHandle 表头为最近访问
+	b1, b2 *list.List // 存放*arcGhost 表头为最近淘汰
+
+	table  map[K]*list.Element // 位于T1/T2中的entry
+	ghosts map[K]*list.Element // 位于B1/B2中的key
+
+	t1Size, t2Size int64
+	b1Size, b2Size int64
+
+	// T1的目标大小：0 <= p <= capacity
+	p int64
+
+	// 当前cache的size(包括已移出cache但仍被handle引用的entry)
+	size int64
+
+	capacity int64
+
+	last_id uint64
+
+	// 命名空间的generation 见namespace.go
+	namespaces namespaces
+
+	// size为0时的计算方式 nil表示SizeOf 见sizer.go
+	sizeFunc atomic.Pointer[SizeFunc[V]]
+
+	// 命中、淘汰等计数 见stats.go(不使用loads、expirations)
+	hits, misses atomic.Int64
+	stats        counters
+
+	// 已关闭
+	closed bool
+}
+
+// 包装key-value存在cache【ARCCache】 引用计数及deleter的约定同LRUHandle
+type ARCHandle[K comparable, V any] struct {
+	c             *_ARCCache[K, V] // 同LRUHandle 不引用ARCCache
+	key           K
+	value         V
+	size          int64
+	deleter       func(key K, value V)
+	time_created  time.Time
+	time_accessed atomic.Value
+	refs          uint32
+	frequent      bool // true: 位于T2  false: 位于T1
+}
+
+// 被淘汰的key
+type arcGhost[K comparable] struct {
+	key      K
+	size     int64
+	frequent bool // true: 位于B2  false: 位于B1
+}
+
+// ========================================ARCHandle=====================================
+
+func (h *ARCHandle[K, V]) Key() K {
+	return h.key
+}
+
+func (h *ARCHandle[K, V]) Value() V {
+	return h.value
+}
+
+func (h *ARCHandle[K, V]) Size() int {
+	return int(h.size)
+}
+
+func (h *ARCHandle[K, V]) TimeCreated() time.Time {
+	return h.time_created
+}
+
+func (h *ARCHandle[K, V]) Time_Accessed() time.Time {
+	return h.time_accessed.Load().(time.Time)
+}
+
+func (h *ARCHandle[K, V]) Retain() (handle *ARCHandle[K, V]) {
+	h.c.mu.Lock()
+	defer h.c.mu.Unlock()
+	h.refs++
+	return h
+}
+
+func (h *ARCHandle[K, V]) Close() error {
+	h.c.mu.Lock()
+	defer h.c.mu.Unlock()
+	h.c.unref(h)
+	return nil
+}
+
+// ========================================ARCCache=====================================
+// 创建ARC cache capacity <= 0时返回ErrInvalidCapacity
+func NewARCCache[K comparable, V any](capacity int64) (*ARCCache[K, V], error) {
+	if err := validateCapacity(capacity); err != nil {
+		return nil, err
+	}
+
+	p := &_ARCCache[K, V]{
+		t1:       list.New(),
+		t2:       list.New(),
+		b1:       list.New(),
+		b2:       list.New(),
+		table:    make(map[K]*list.Element),
+		ghosts:   make(map[K]*list.Element),
+		capacity: capacity,
+	}
+
+	c := &ARCCache[K, V]{p}
+	runtime.SetFinalizer(c, (*ARCCache[K, V]).Close) // 退出清理Cache 同LRUCache
+	return c, nil
+}
+
+// 关闭cache 约定同LRUCache.Close
+func (p *ARCCache[K, V]) Close() error {
+	runtime.SetFinalizer(p, nil)
+	return p._ARCCache.Close()
+}
+
+//
+func (p *ARCCache[K, V]) NewId() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.last_id++
+	return p.last_id
+}
+
+// 插入
+func (p *ARCCache[K, V]) Insert(key K, value V, size int, deleter func(key K, value V)) (io.Closer, error) {
+	h, err := p.Insert_(key, value, size, deleter)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// 插入并返回handle 错误同LRUCache.Insert_
+func (p *ARCCache[K, V]) Insert_(key K, value V, size int, deleter func(key K, value V)) (handle *ARCHandle[K, V], err error) {
+	size = resolveSize(&p.sizeFunc, value, size)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrCacheClosed
+	}
+	if err = validateEntry(key, size, p.capacity); err != nil {
+		return nil, err
+	}
+
+	h := &ARCHandle[K, V]{
+		c:            p._ARCCache,
+		key:          key,
+		value:        value,
+		size:         int64(size),
+		deleter:      deleter,
+		time_created: time.Now(),
+		refs:         2, // 1 ---> ARCCache   2 ----> 返回值handle
+	}
+	h.time_accessed.Store(h.time_created)
+
+	p.stats.inserts.Add(1)
+	if element := p.table[key]; element != nil { // 已存在：再次访问 进入T2
+		p.remove(element)
+		p.stats.replacements.Add(1)
+		h.frequent = true
+	} else if element := p.ghosts[key]; element != nil { // 命中ghost：调整p后进入T2
+		g := element.Value.(*arcGhost[K])
+		if g.frequent { // 命中B2：T2过小 减小p
+			p.p = max(p.p-ratio(p.b1Size, p.b2Size)*h.size, 0)
+		} else { // 命中B1：T1过小 增大p
+			p.p = min(p.p+ratio(p.b2Size, p.b1Size)*h.size, p.capacity)
+		}
+		p.removeGhost(element)
+		h.frequent = true
+	}
+
+	if h.frequent {
+		p.table[key] = p.t2.PushFront(h)
+		p.t2Size += h.size
+	} else {
+		p.table[key] = p.t1.PushFront(h)
+		p.t1Size += h.size
+	}
+	p.size += h.size
+
+	p.checkCapacity(h)
+	return h, nil
+}
+
+// 查询
+func (p *ARCCache[K, V]) Lookup(key K) (value V, handle io.Closer, ok bool) {
+	if v, h, ok := p.Lookup_(key); ok {
+		return v, h, ok
+	}
+	return
+}
+
+func (p *ARCCache[K, V]) Lookup_(key K) (value V, handle *ARCHandle[K, V], ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element := p.table[key]
+	if element == nil {
+		p.misses.Add(1)
+		return value, nil, false
+	}
+	p.hits.Add(1)
+
+	// 命中：移动到T2表头
+	h := element.Value.(*ARCHandle[K, V])
+	if h.frequent {
+		p.t2.MoveToFront(element)
+	} else {
+		p.t1.Remove(element)
+		p.t1Size -= h.size
+		h.frequent = true
+		p.table[key] = p.t2.PushFront(h)
+		p.t2Size += h.size
+	}
+	h.time_accessed.Store(time.Now())
+	h.refs++
+
+	return h.value, h, true
+}
+
+// 删除
+func (p *ARCCache[K, V]) Erase(key K) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element := p.table[key]; element != nil {
+		p.remove(element)
+		p.stats.erases.Add(1)
+	}
+	if element := p.ghosts[key]; element != nil {
+		p.removeGhost(element)
+	}
+}
+
+// 查询
+func (p *ARCCache[K, V]) Get(key K) (value V, ok bool) {
+	if v, h, ok := p.Lookup(key); ok {
+		h.Close()
+		return v, ok
+	}
+	return
+}
+
+// 设置
+func (p *ARCCache[K, V]) Set(key K, value V, size int, deleter ...func(key K, value V)) error {
+	var d func(key K, value V)
+	if len(deleter) > 0 {
+		d = deleter[0]
+	}
+	h, err := p.Insert_(key, value, size, d)
+	if err != nil {
+		return err
+	}
+	return h.Close()
+}
+
+// 获取value
+// 若是cache中没有对应的value，取defaultValue第一个元素作为结果返回
+func (p *ARCCache[K, V]) Value(key K, defaultValue ...V) (value V) {
+	if v, h, ok := p.Lookup(key); ok {
+		h.Close()
+		return v
+	}
+
+	if len(defaultValue) > 0 {
+		return defaultValue[0]
+	}
+	return
+}
+
+// 设置cache的capacity
+func (p *ARCCache[K, V]) SetCapacity(capacity int64) error {
+	if err := validateCapacity(capacity); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.capacity = capacity
+	p.p = min(p.p, capacity)
+	p.checkCapacity(nil)
+	return nil
+}
+
+// cache中element的个数
+func (p *ARCCache[K, V]) Length() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return int64(len(p.table))
+}
+
+//
+func (p *ARCCache[K, V]) Size() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.size
+}
+
+func (p *ARCCache[K, V]) Capacity() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.capacity
+}
+
+// ARC内部状态：T1/T2/B1/B2各自的size 以及T1的目标大小
+func (p *ARCCache[K, V]) ARCStats() (t1, t2, b1, b2, target int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.t1Size, p.t2Size, p.b1Size, p.b2Size, p.p
+}
+
+// 统计信息快照 不包括分段及加载相关的计数
+func (p *ARCCache[K, V]) StatsSnapshot() (s StatsSnapshot) {
+	p.mu.Lock()
+	s.Length, s.Size, s.Capacity = int64(len(p.table)), p.size, p.capacity
+	for _, l := range []*list.List{p.t1, p.t2} { // 各自的表尾为最久未访问
+		if back := l.Back(); back != nil {
+			if t := back.Value.(*ARCHandle[K, V]).Time_Accessed(); s.OldestAccess.IsZero() || t.Before(s.OldestAccess) {
+				s.OldestAccess = t
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	s.Hits = p.hits.Load()
+	s.Misses = p.misses.Load()
+	s.Inserts = p.stats.inserts.Load()
+	s.Replacements = p.stats.replacements.Load()
+	s.Evictions = p.stats.evictions.Load()
+	s.Erases = p.stats.erases.Load()
+	return s
+}
+
+// 重置所有计数 不影响cache中的entry
+func (p *ARCCache[K, V]) ResetStats() {
+	p.hits.Store(0)
+	p.misses.Store(0)
+	p.stats.inserts.Store(0)
+	p.stats.replacements.Store(0)
+	p.stats.evictions.Store(0)
+	p.stats.erases.Store(0)
+}
+
+// 命中率
+func (p *ARCCache[K, V]) HitRatio() float64 {
+	return p.StatsSnapshot().HitRatio()
+}
+
+// 统计信息json格式：额外输出ARC的内部状态
+func (p *ARCCache[K, V]) StatsJSON() string {
+	if p == nil {
+		return "{}"
+	}
+	s := p.StatsSnapshot()
+	t1, t2, b1, b2, target := p.ARCStats()
+	b, err := json.MarshalIndent(struct {
+		StatsSnapshot
+		HitRatio       float64
+		T1, T2, B1, B2 int64
+		Target         int64
+	}{s, s.HitRatio(), t1, t2, b1, b2, target}, "", "\t")
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// 清除cache
+// 前提要release所有key关联的handle
+func (p *ARCCache[K, V]) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, element := range p.table {
+		p.remove(element)
+	}
+	p.b1.Init()
+	p.b2.Init()
+	p.ghosts = make(map[K]*list.Element)
+	p.b1Size, p.b2Size, p.p = 0, 0, 0
+}
+
+// ARC自适应调整的步长倍数：max(a/b, 1)
+func ratio(a, b int64) int64 {
+	if b == 0 || a < b {
+		return 1
+	}
+	return a / b
+}
+
+// 检查cache的size是否已经超过capacity
+// 一旦超过了 则按照ARC的REPLACE进行淘汰：被淘汰的key进入B1/B2
+// 与LRUCache一样 至少保留一个entry；刚插入的entry不会被淘汰
+func (p *ARCCache[K, V]) checkCapacity(inserted *ARCHandle[K, V]) {
+	for p.t1Size+p.t2Size > p.capacity && len(p.table) > 1 {
+		fromT1 := p.t1.Len() > 0 && (p.t1Size > p.p || p.t2.Len() == 0)
+		if fromT1 && p.t1.Back().Value == inserted && p.t2.Len() > 0 {
+			fromT1 = false
+		} else if !fromT1 && p.t2.Back().Value == inserted && p.t1.Len() > 0 {
+			fromT1 = true
+		}
+
+		var element *list.Element
+		if fromT1 {
+			element = p.t1.Back()
+		} else {
+			element = p.t2.Back()
+		}
+		h := element.Value.(*ARCHandle[K, V])
+		p.remove(element)
+		p.stats.evictions.Add(1)
+
+		g := &arcGhost[K]{key: h.key, size: h.size, frequent: h.frequent}
+		if g.frequent {
+			p.ghosts[g.key] = p.b2.PushFront(g)
+			p.b2Size += g.size
+		} else {
+			p.ghosts[g.key] = p.b1.PushFront(g)
+			p.b1Size += g.size
+		}
+	}
+
+	// ghost的总大小不超过capacity：T1+B1 <= capacity 其余从B2淘汰
+	for p.b1.Len() > 0 && p.t1Size+p.b1Size > p.capacity {
+		p.removeGhost(p.b1.Back())
+	}
+	for p.b1Size+p.b2Size > p.capacity {
+		if p.b2.Len() > 0 {
+			p.removeGhost(p.b2.Back())
+		} else {
+			p.removeGhost(p.b1.Back())
+		}
+	}
+}
+
+// 从T1/T2中移除entry 并release cache持有的handle
+func (p *_ARCCache[K, V]) remove(element *list.Element) {
+	h := element.Value.(*ARCHandle[K, V])
+	if h.frequent {
+		p.t2.Remove(element)
+		p.t2Size -= h.size
+	} else {
+		p.t1.Remove(element)
+		p.t1Size -= h.size
+	}
+	delete(p.table, h.key)
+	p.unref(h)
+}
+
+// 从B1/B2中移除ghost
+func (p *_ARCCache[K, V]) removeGhost(element *list.Element) {
+	g := element.Value.(*arcGhost[K])
+	if g.frequent {
+		p.b2.Remove(element)
+		p.b2Size -= g.size
+	} else {
+		p.b1.Remove(element)
+		p.b1Size -= g.size
+	}
+	delete(p.ghosts, g.key)
+}
+
+func (p *_ARCCache[K, V]) unref(h *ARCHandle[K, V]) {
+	common.Assert(h.refs > 0)
+	h.refs--
+	if h.refs <= 0 {
+		if !p.closed { // 同LRUCache
+			p.size -= h.size
+		}
+		if h.deleter != nil {
+			h.deleter(h.key, h.value)
+		}
+	}
+}
+
+//==========================================实现io.Closer==========================================
+// 若仍有handle未被释放 返回*HandleLeakError cache仍会被关闭
+func (p *_ARCCache[K, V]) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+
+	var leaks []OutstandingHandle
+	for _, element := range p.table {
+		h := element.Value.(*ARCHandle[K, V])
+		if h.refs > 1 {
+			leaks = append(leaks, OutstandingHandle{Key: h.key, Refs: int(h.refs) - 1})
+		}
+		p.remove(element)
+	}
+
+	// 关闭后保留空的list和table：查询等操作无需额外判断
+	p.closed = true
+	p.t1, p.t2, p.b1, p.b2 = list.New(), list.New(), list.New(), list.New()
+	p.table, p.ghosts = make(map[K]*list.Element), make(map[K]*list.Element)
+	p.t1Size, p.t2Size, p.b1Size, p.b2Size = 0, 0, 0, 0
+	p.size = 0
+	if len(leaks) > 0 {
+		return &HandleLeakError{Handles: leaks}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+)
+
+func TestARCStatsJSON(t *testing.T) {
+	c, err := NewARCCache[string, int](2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Set("a", 1, 1)
+	c.Set("b", 2, 1)
+	c.Get("a")
+	c.Get("missing")
+	c.Set("a", 3, 1)
+	c.Set("c", 4, 1) // 淘汰b
+	c.Erase("c")
+
+	var got struct {
+		StatsSnapshot
+		HitRatio float64
+		T1, T2   int64
+		B1, B2   int64
+		Target   int64
+	}
+	if err := json.Unmarshal([]byte(c.StatsJSON()), &got); err != nil {
+		t.Fatalf("StatsJSON is not valid JSON: %v", err)
+	}
+	want := StatsSnapshot{Length: 1, Size: 1, Capacity: 2, Hits: 1, Misses: 1, Inserts: 4, Replacements: 1, Evictions: 1, Erases: 1}
+	got.OldestAccess = want.OldestAccess
+	if got.StatsSnapshot != want {
+		t.Errorf("StatsJSON snapshot = %+v, want %+v", got.StatsSnapshot, want)
+	}
+	if got.HitRatio != 0.5 {
+		t.Errorf("HitRatio = %v, want 0.5", got.HitRatio)
+	}
+	if got.T2 != 1 || got.B1 != 1 {
+		t.Errorf("T2, B1 = %d, %d, want 1, 1", got.T2, got.B1)
+	}
+}
+
+func TestARCReleaseAfterCloseKeepsSize(t *testing.T) {
+	c, err := NewARCCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := c.Insert("a", 1, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leak *HandleLeakError
+	if err := c.Close(); !errors.As(err, &leak) {
+		t.Fatalf("Close = %v, want *HandleLeakError", err)
+	}
+	h.Close()
+	if got := c.Size(); got != 0 {
+		t.Errorf("Size after releasing leaked handle = %d, want 0", got)
+	}
+}
+
+// 命中B1增大T1的目标大小p 命中B2减小p
+func TestARCGhostHitsAdaptTarget(t *testing.T) {
+	c, err := NewARCCache[string, int](2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Set("a", 1, 1)
+	c.Get("a")
+	c.Set("b", 2, 1)
+	c.Get("b")       // T2: b a
+	c.Set("c", 3, 1) // T2的a进入B2
+	c.Set("d", 4, 1) // T1的c进入B1
+	if t1, t2, b1, b2, target := c.ARCStats(); t1 != 1 || t2 != 1 || b1 != 1 || b2 != 1 || target != 0 {
+		t.Fatalf("ARCStats() = %d, %d, %d, %d, %d, want 1, 1, 1, 1, 0", t1, t2, b1, b2, target)
+	}
+
+	c.Set("c", 3, 1) // 命中B1
+	if _, _, _, _, target := c.ARCStats(); target != 1 {
+		t.Fatalf("target after B1 hit = %d, want 1", target)
+	}
+	c.Set("a", 1, 1) // 命中B2
+	if _, _, _, _, target := c.ARCStats(); target != 0 {
+		t.Fatalf("target after B2 hit = %d, want 0", target)
+	}
+	for _, key := range []string{"a", "c"} { // 命中ghost的key进入T2
+		if e := c.table[key]; e == nil || !e.Value.(*ARCHandle[string, int]).frequent {
+			t.Errorf("%q is not in T2 after a ghost hit", key)
+		}
+	}
+}
+
+// 任意访问序列下 T1+B1 <= capacity 且 B1+B2 <= capacity
+func TestARCGhostsBounded(t *testing.T) {
+	const capacity = 10
+	c, err := NewARCCache[int, int](capacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 5000; i++ {
+		key := r.IntN(40)
+		if r.IntN(2) == 0 {
+			c.Set(key, i, 1+r.IntN(3))
+		} else {
+			c.Get(key)
+		}
+
+		t1, t2, b1, b2, target := c.ARCStats()
+		if t1+b1 > capacity || b1+b2 > capacity || t1+t2 > capacity || target < 0 || target > capacity {
+			t.Fatalf("step %d: T1 %d T2 %d B1 %d B2 %d target %d, capacity %d", i, t1, t2, b1, b2, target, capacity)
+		}
+		if n := c.b1.Len() + c.b2.Len(); len(c.ghosts) != n {
+			t.Fatalf("step %d: %d ghosts indexed, %d in B1/B2", i, len(c.ghosts), n)
+		}
+	}
+}
+
+// 只访问一次的扫描不会把T2中的热点entry淘汰
+func TestARCScanResistance(t *testing.T) {
+	c, err := NewARCCache[string, int](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	hot := []string{"h0", "h1", "h2", "h3", "h4"}
+	for i, key := range hot {
+		c.Set(key, i, 1)
+		c.Get(key)
+	}
+	for i := 0; i < 1000; i++ {
+		c.Set("scan"+strconv.Itoa(i), i, 1)
+	}
+
+	for _, key := range hot {
+		if _, ok := c.table[key]; !ok {
+			t.Errorf("hot key %q evicted by a scan", key)
+		}
+	}
+	if _, t2, _, _, _ := c.ARCStats(); t2 != int64(len(hot)) {
+		t.Errorf("T2 size after scan = %d, want %d", t2, len(hot))
+	}
+}
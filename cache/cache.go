@@ -11,13 +11,16 @@ type Cache interface {
 	// 返回一个新的数值ID。
 	// 常用于多clients共享相同cache来分片key空间
 	// 当client启动时分配一个新的ID,并可以采用id_key的形式
+	// 或者直接使用NamespacedCache.Namespace(id)返回的视图
 	NewId()	uint64
 
 	//  插入: 建立一个从key-value到cache的映射，同时分配指定size相当于cache的总容量(capacity)
 	//  返回handle(相当于mapping【key:value ---> cache】).
 	// 注：当返回的handle mapping不再需要，调用方必须调用handle.Close()
 	//     当插入entry不再需要的时候，key-value会传递给“deleter”，由调用方处理
-	Insert(key string, value interface{}, size int, deleter func(key string, value interface{})) (handle io.Closer)
+	//     size为0时自动计算：value实现了Sizer时使用其Size() 否则估算其占用的内存 见SizeOf
+	//     key为空、size无效、size超过capacity或cache已关闭时返回错误(ErrEmptyKey、ErrInvalidSize、ErrEntryTooLarge、ErrCacheClosed)
+	Insert(key string, value interface{}, size int, deleter func(key string, value interface{})) (handle io.Closer, err error)
 
 
 	// 查询
@@ -38,7 +41,76 @@ type Cache interface {
 	Close() error
 }
 
+// cache的淘汰算法
+type Algorithm int
+
+const (
+	AlgorithmLRU Algorithm = iota // 默认：LRUCache
+	AlgorithmARC                  // ARCCache
+	AlgorithmSLRU                 // 分段模式的LRUCache：见LRUCache.SetSegmented
+	AlgorithmTinyLFU              // W-TinyLFU准入 + 分段模式的LRUCache：见LRUCache.EnableTinyLFU
+)
+
+// New的可选项
+type Option func(o *options)
+
+type options struct {
+	algorithm Algorithm
+}
+
+// 指定cache的淘汰算法
+func WithAlgorithm(algorithm Algorithm) Option {
+	return func(o *options) {
+		o.algorithm = algorithm
+	}
+}
+
+// 支持命名空间的Cache 见namespace.go
+type NamespacedCache interface {
+	Cache
+
+	// 命名空间视图：视图中的key自动限定在id对应的key空间内
+	// 注：视图的Close只释放视图本身(之后的操作返回ErrCacheClosed或未命中) 不会使命名空间失效 也不会关闭cache
+	Namespace(id uint64) (NamespacedCache, error)
+
+	// 使命名空间中的所有entry立即失效(查询均未命中)
+	// 失效的entry由淘汰回收 届时照常调用deleter
+	InvalidateNamespace(id uint64)
+}
+
 // 根据指定capacity创建cache
-func New(capacity int64) Cache{
-	return NewLRUCache(capacity)
+// 底层为泛型LRUCache[string, interface{}]：即Cache接口是泛型版本的一个适配
+// 可通过WithAlgorithm选择其他的淘汰算法
+// capacity <= 0时返回ErrInvalidCapacity
+func New(capacity int64, opts ...Option) (Cache, error){
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.algorithm == AlgorithmARC {
+		c, err := NewARCCache[string, interface{}](capacity)
+		if err != nil {
+			return nil, err // 不能直接返回：nil的*ARCCache包装为Cache后不等于nil
+		}
+		return c, nil
+	}
+
+	c, err := NewLRUCache[string, interface{}](capacity)
+	if err != nil {
+		return nil, err
+	}
+	switch o.algorithm {
+	case AlgorithmSLRU:
+		err = c.SetSegmented(DefaultProtectedRatio)
+	case AlgorithmTinyLFU:
+		if err = c.SetSegmented(DefaultProtectedRatio); err == nil {
+			err = c.EnableTinyLFU(DefaultWindowRatio)
+		}
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
 }
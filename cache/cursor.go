@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// Range每次在锁内收集的entry个数
+const DefaultCursorPageSize = 64
+
+// 按照双向链表的顺序分页遍历cache 每页只在收集entry时持有锁
+// 遍历期间允许并发的插入、删除和淘汰：
+// 游标停留的entry被移除或移动时 游标退回到已遍历过的相邻entry 游标不会因此失效
+// 遍历期间一直在cache中且未被移动的entry恰好被遍历一次；新插入或被移动的entry可能被遗漏或重复遍历
+// 已过期的entry被跳过
+// 注：未遍历完的游标必须Close 否则cache会一直维护该游标
+type Cursor[K comparable, V any] struct {
+	c        *_LRUCache[K, V]
+	element  *list.Element // 最后遍历的entry nil表示从头开始
+	backward bool          // 从表尾向表头遍历
+	done     bool
+}
+
+// 从表头(最近使用)向表尾遍历的游标
+func (p *LRUCache[K, V]) FrontCursor() *Cursor[K, V] {
+	return &Cursor[K, V]{c: p._LRUCache}
+}
+
+// 从表尾(最久未使用)向表头遍历的游标
+func (p *LRUCache[K, V]) BackCursor() *Cursor[K, V] {
+	return &Cursor[K, V]{c: p._LRUCache, backward: true}
+}
+
+// 返回下一页最多n个entry的handle n <= 0时使用DefaultCursorPageSize
+// 返回的handle均已retain 调用方使用完后必须Close
+// 返回空时表示遍历结束
+func (c *Cursor[K, V]) Next(n int) []*LRUHandle[K, V] {
+	if n <= 0 {
+		n = DefaultCursorPageSize
+	}
+
+	p := c.c
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c.done {
+		return nil
+	}
+
+	now := time.Now()
+	handles := make([]*LRUHandle[K, V], 0, min(n, p.list.Len()))
+	element := c.step(c.element)
+	for ; element != nil && len(handles) < n; element = c.step(element) {
+		h := element.Value.(*LRUHandle[K, V])
+		c.element = element
+		if h.Expired(now) {
+			continue
+		}
+		p.addref(h)
+		p.acquire(h)
+		handles = append(handles, h)
+	}
+
+	if element == nil { // 已到达链表的另一端
+		c.finish()
+	} else if p.cursors == nil {
+		p.cursors = map[*Cursor[K, V]]struct{}{c: {}}
+	} else {
+		p.cursors[c] = struct{}{}
+	}
+	return handles
+}
+
+// 结束遍历
+func (c *Cursor[K, V]) Close() error {
+	c.c.mu.Lock()
+	defer c.c.mu.Unlock()
+
+	c.finish()
+	return nil
+}
+
+// 遍历方向上的下一个element 必须持有锁
+func (c *Cursor[K, V]) step(element *list.Element) *list.Element {
+	switch {
+	case element == nil && c.backward:
+		return c.c.list.Back()
+	case element == nil:
+		return c.c.list.Front()
+	case c.backward:
+		return element.Prev()
+	default:
+		return element.Next()
+	}
+}
+
+// 必须持有锁
+func (c *Cursor[K, V]) finish() {
+	c.done = true
+	c.element = nil
+	delete(c.c.cursors, c)
+}
+
+// 按照双向链表的顺序(从表头到表尾)遍历cache 直至fn返回false
+// h仅在fn执行期间有效 需要保留时使用h.Retain()
+// fn执行时不持有锁 可以调用cache的方法；遍历期间的并发修改见Cursor
+func (p *LRUCache[K, V]) Range(fn func(h *LRUHandle[K, V]) bool) {
+	cursor := p.FrontCursor()
+	defer cursor.Close()
+
+	for {
+		handles := cursor.Next(DefaultCursorPageSize)
+		if len(handles) == 0 {
+			return
+		}
+		for i, h := range handles {
+			ok := fn(h)
+			h.Close()
+			if !ok {
+				for _, h := range handles[i+1:] {
+					h.Close()
+				}
+				return
+			}
+		}
+	}
+}
+
+// element即将被移除或移动：停留在该element的游标退回到已遍历过的相邻element
+// 必须持有锁
+func (p *_LRUCache[K, V]) unanchor(element *list.Element) {
+	for c := range p.cursors {
+		if c.element != element {
+			continue
+		}
+		if c.backward {
+			c.element = element.Next()
+		} else {
+			c.element = element.Prev()
+		}
+	}
+}
+
+// 必须持有锁
+func (p *_LRUCache[K, V]) moveToFront(element *list.Element) {
+	if p.list.Front() != element {
+		p.unanchor(element)
+		p.list.MoveToFront(element)
+	}
+}
+
+// cache被清空或关闭：结束所有游标 必须持有锁
+func (p *_LRUCache[K, V]) finishCursors() {
+	for c := range p.cursors {
+		c.finish()
+	}
+}
+
+// 依次遍历所有分片 每个分片按照双向链表的顺序 直至fn返回false
+func (p *ShardedLRUCache) Range(fn func(h *LRUHandle[string, interface{}]) bool) {
+	for _, s := range p.shards {
+		stop := false
+		s.Range(func(h *LRUHandle[string, interface{}]) bool {
+			stop = !fn(h)
+			return !stop
+		})
+		if stop {
+			return
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// 插入0..n-1：表头为n-1
+func newCursorCache(t *testing.T, capacity int64, n int) *LRUCache[int, int] {
+	t.Helper()
+	c, err := NewLRUCache[int, int](capacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	for i := 0; i < n; i++ {
+		c.Set(i, i, 1)
+	}
+	return c
+}
+
+// 取下一页的key并释放handle
+func nextKeys(c *Cursor[int, int], n int) (keys []int) {
+	for _, h := range c.Next(n) {
+		keys = append(keys, h.Key())
+		h.Close()
+	}
+	return keys
+}
+
+func drainKeys(c *Cursor[int, int]) (keys []int) {
+	for page := nextKeys(c, 3); page != nil; page = nextKeys(c, 3) {
+		keys = append(keys, page...)
+	}
+	return keys
+}
+
+// 所有entry只被cache自身引用
+func checkNoRefs(t *testing.T, c *LRUCache[int, int]) {
+	t.Helper()
+	for _, key := range c.Keys() {
+		if info, _ := c.Entry(key); info.Refs != 0 {
+			t.Errorf("entry %d has %d outstanding refs", key, info.Refs)
+		}
+	}
+	if n := len(c.cursors); n != 0 {
+		t.Errorf("%d cursors still registered", n)
+	}
+}
+
+func TestCursorPaging(t *testing.T) {
+	c := newCursorCache(t, 100, 7)
+
+	cur := c.FrontCursor()
+	page := cur.Next(3)
+	if len(page) != 3 {
+		t.Fatalf("Next(3) returned %d handles", len(page))
+	}
+	for _, h := range page { // 页中的handle均被retain
+		if info, _ := c.Entry(h.Key()); info.Refs != 1 {
+			t.Errorf("entry %d: Refs = %d while the page is held, want 1", h.Key(), info.Refs)
+		}
+		h.Close()
+	}
+	if got := fmt.Sprint(drainKeys(cur)); got != "[3 2 1 0]" {
+		t.Errorf("rest of the front cursor = %s", got)
+	}
+	if cur.Next(3) != nil {
+		t.Error("Next after the end returned handles")
+	}
+
+	if got := fmt.Sprint(drainKeys(c.BackCursor())); got != "[0 1 2 3 4 5 6]" {
+		t.Errorf("back cursor = %s", got)
+	}
+	cur = c.FrontCursor()
+	nextKeys(cur, 2)
+	cur.Close() // 未遍历完：Close后不再被cache维护
+	if cur.Next(1) != nil {
+		t.Error("Next after Close returned handles")
+	}
+	checkNoRefs(t, c)
+}
+
+// 游标停留的entry被删除或移动：退回到已遍历过的相邻entry 继续遍历剩余的entry
+func TestCursorUnanchor(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		backward bool
+		first    int
+		modify   func(c *LRUCache[int, int])
+		want     string
+	}{
+		{"erase", false, 2, func(c *LRUCache[int, int]) { c.Erase(4) }, "[3 2 1 0]"},
+		{"erase front", false, 1, func(c *LRUCache[int, int]) { c.Erase(5) }, "[4 3 2 1 0]"},
+		{"move to front", false, 2, func(c *LRUCache[int, int]) { c.Get(4) }, "[3 2 1 0]"},
+		{"move to back", false, 2, func(c *LRUCache[int, int]) { c.MoveToBack(4) }, "[3 2 1 0 4]"},
+		{"erase backward", true, 2, func(c *LRUCache[int, int]) { c.Erase(1) }, "[2 3 4 5]"},
+		{"erase back", true, 1, func(c *LRUCache[int, int]) { c.Erase(0) }, "[1 2 3 4 5]"},
+		{"move to front backward", true, 2, func(c *LRUCache[int, int]) { c.Get(1) }, "[2 3 4 5 1]"},
+		{"clear", false, 2, func(c *LRUCache[int, int]) { c.Clear() }, "[]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newCursorCache(t, 100, 6)
+			cur := c.FrontCursor()
+			if tc.backward {
+				cur = c.BackCursor()
+			}
+			nextKeys(cur, tc.first)
+			tc.modify(c)
+			if got := fmt.Sprint(drainKeys(cur)); got != tc.want {
+				t.Errorf("keys after %s = %s, want %s", tc.name, got, tc.want)
+			}
+			checkNoRefs(t, c)
+		})
+	}
+}
+
+// 遍历期间并发的插入、删除和淘汰：go test -race
+// 一直在cache中的entry恰好被遍历一次 遍历开始后插入的entry(位于表头)不会被遍历
+func TestCursorConcurrentModification(t *testing.T) {
+	const initial = 500
+	c := newCursorCache(t, initial+50, initial)
+
+	var mu sync.Mutex
+	removed := map[int]bool{}
+	c.SetRemovalListener(func(key, value int, size int64, reason RemovalReason) {
+		mu.Lock()
+		removed[key] = true
+		mu.Unlock()
+	})
+
+	cur := c.FrontCursor()
+	seen := map[int]int{}
+	for _, key := range nextKeys(cur, 1) {
+		seen[key]++
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 300; i++ {
+				key := initial + g*1000 + i
+				c.Set(key, key, 1) // 超出容量时淘汰表尾
+				if i%3 == 0 {
+					c.Erase(key - 1)
+					c.Erase((g*131 + i*7) % initial)
+				}
+				if i >= 2 {
+					c.Get(key - 2) // 只移动新插入的entry
+				}
+			}
+		}(g)
+	}
+	for page := nextKeys(cur, 7); page != nil; page = nextKeys(cur, 7) {
+		for _, key := range page {
+			seen[key]++
+		}
+	}
+	wg.Wait()
+
+	for key, n := range seen {
+		if key >= initial {
+			t.Errorf("key %d inserted after the cursor started was visited", key)
+		} else if n != 1 {
+			t.Errorf("key %d visited %d times", key, n)
+		}
+	}
+	for key := 0; key < initial; key++ {
+		if !removed[key] && seen[key] != 1 {
+			t.Errorf("key %d stayed in the cache but was visited %d times", key, seen[key])
+		}
+	}
+	checkNoRefs(t, c)
+}
+
+// Range结束或fn提前返回false后 所有handle均已释放
+func TestRangeReleasesHandles(t *testing.T) {
+	c := newCursorCache(t, 200, 150) // 超过一页
+
+	var visited []int
+	c.Range(func(h *LRUHandle[int, int]) bool {
+		visited = append(visited, h.Key())
+		return len(visited) < 70 // 在第二页中停止
+	})
+	if len(visited) != 70 || visited[0] != 149 || visited[69] != 80 {
+		t.Fatalf("Range visited %d keys from %d to %d", len(visited), visited[0], visited[len(visited)-1])
+	}
+	checkNoRefs(t, c)
+
+	var kept *LRUHandle[int, int]
+	n := 0
+	c.Range(func(h *LRUHandle[int, int]) bool {
+		n++
+		if h.Key() == 100 {
+			kept = h.Retain() // 保留到Range之后
+		}
+		if h.Key()%2 == 0 {
+			c.Erase(h.Key()) // fn中可以修改cache
+		}
+		return true
+	})
+	if n != 150 || c.Length() != 75 {
+		t.Fatalf("Range visited %d keys, Length = %d", n, c.Length())
+	}
+	if kept == nil || kept.Value() != 100 {
+		t.Fatalf("retained handle = %v", kept)
+	}
+	size := c.Size()
+	kept.Close() // 已被Erase：释放最后一个引用后扣减size
+	if c.Size() != size-1 {
+		t.Errorf("Size after releasing the retained handle = %d, want %d", c.Size(), size-1)
+	}
+	checkNoRefs(t, c)
+}
+
+func TestShardedRange(t *testing.T) {
+	c, err := NewShardedLRUCache(1000, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < 100; i++ {
+		c.Set(strconv.Itoa(i), i, 1)
+	}
+
+	seen := map[string]int{}
+	c.Range(func(h *LRUHandle[string, interface{}]) bool {
+		seen[h.Key()]++
+		return true
+	})
+	if len(seen) != 100 {
+		t.Errorf("Range visited %d keys, want 100", len(seen))
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("key %q visited %d times", key, n)
+		}
+	}
+
+	n := 0
+	c.Range(func(h *LRUHandle[string, interface{}]) bool {
+		n++
+		return n < 30
+	})
+	if n != 30 {
+		t.Errorf("Range after returning false visited %d keys, want 30", n)
+	}
+	for _, o := range c.OutstandingHandles() {
+		t.Errorf("outstanding handle after Range: %v", o)
+	}
+}
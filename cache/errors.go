@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 参数错误 均可通过errors.Is判断
+var (
+	ErrEmptyKey        = errors.New("cache: empty key")
+	ErrInvalidSize     = errors.New("cache: invalid size")     // entry的size < 0 或自动计算的size <= 0
+	ErrInvalidCapacity = errors.New("cache: invalid capacity") // capacity <= 0
+	ErrEntryTooLarge   = errors.New("cache: entry too large")  // entry的size超过cache的capacity
+	ErrCacheClosed     = errors.New("cache: closed")
+	ErrInvalidRatio    = errors.New("cache: invalid ratio") // 分段、window的占比不在(0, 1)内
+	ErrNotFound        = errors.New("cache: not found")     // GetFrom的getter为nil且key不在cache中
+
+	// 命名空间视图基于Cache接口：key不为string或value不为interface{}的cache不支持
+	ErrNamespaceUnsupported = errors.New("cache: namespaces require string keys and interface{} values")
+)
+
+// 校验entry的key和size
+func validateEntry[K comparable](key K, size int, capacity int64) error {
+	if isEmptyKey(key) {
+		return ErrEmptyKey
+	}
+	if size <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidSize, size)
+	}
+	if int64(size) > capacity {
+		return fmt.Errorf("%w: size %d exceeds capacity %d", ErrEntryTooLarge, size, capacity)
+	}
+	return nil
+}
+
+// 校验capacity
+func validateCapacity(capacity int64) error {
+	if capacity <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidCapacity, capacity)
+	}
+	return nil
+}
+
+// 校验分段、window的占比
+func validateRatio(name string, ratio float64) error {
+	if !(ratio > 0 && ratio < 1) { // 同时排除NaN
+		return fmt.Errorf("%w: %s ratio %v", ErrInvalidRatio, name, ratio)
+	}
+	return nil
+}
+
+// 快照数据无效：版本不符、校验失败或数据被截断 见Restore
+var ErrInvalidSnapshot = errors.New("cache: invalid snapshot")
+
+// WAL日志损坏：除最后一个日志文件末尾的不完整记录外 其余的损坏都无法恢复 见EnableWAL
+var ErrCorruptWAL = errors.New("cache: corrupt WAL")
+
+// 加载被取消或超时：调用方的context已结束
+// 可通过errors.Is(err, context.Canceled) / errors.Is(err, context.DeadlineExceeded)判断具体原因
+type LoadCanceledError struct {
+	Key interface{}
+	Err error // ctx.Err()
+}
+
+func (e *LoadCanceledError) Error() string {
+	return fmt.Sprintf("cache: load of %v canceled: %v", e.Key, e.Err)
+}
+
+func (e *LoadCanceledError) Unwrap() error {
+	return e.Err
+}
+
+// Close时仍有handle未被释放
+type HandleLeakError struct {
+	Handles []OutstandingHandle
+}
+
+func (e *HandleLeakError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cache: %d handle(s) still in use at Close", len(e.Handles))
+	for _, h := range e.Handles {
+		b.WriteString("\n")
+		b.WriteString(h.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// 默认的过期清理周期
+const DefaultSweepInterval = time.Minute
+
+// Insert_/SetWithOptions的可选项
+type EntryOption func(o *entryOptions)
+
+type entryOptions struct {
+	ttl  time.Duration // 绝对有效期：自插入起计算
+	idle time.Duration // 空闲有效期：自最后一次访问起计算
+}
+
+// 设置entry的绝对有效期：插入ttl之后过期
+func WithTTL(ttl time.Duration) EntryOption {
+	return func(o *entryOptions) {
+		o.ttl = ttl
+	}
+}
+
+// 设置entry的空闲有效期：超过idle未被访问则过期
+func WithIdleTimeout(idle time.Duration) EntryOption {
+	return func(o *entryOptions) {
+		o.idle = idle
+	}
+}
+
+// 将选项应用到handle
+func (h *LRUHandle[K, V]) applyOptions(opts []EntryOption) {
+	var o entryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.ttl > 0 {
+		h.expires = h.time_created.Add(o.ttl)
+	}
+	if o.idle > 0 {
+		h.idle = o.idle
+	}
+}
+
+// entry的过期时间 若没有设置ttl则返回IsZero() time
+func (h *LRUHandle[K, V]) ExpiresAt() time.Time {
+	return h.expires
+}
+
+// entry的空闲有效期 0表示不限制
+func (h *LRUHandle[K, V]) IdleTimeout() time.Duration {
+	return h.idle
+}
+
+// entry是否在now时已过期
+func (h *LRUHandle[K, V]) Expired(now time.Time) bool {
+	if !h.expires.IsZero() && !now.Before(h.expires) {
+		return true
+	}
+	return h.idle > 0 && now.Sub(h.Time_Accessed()) >= h.idle
+}
+
+// entry是否可能过期
+func (h *LRUHandle[K, V]) expirable() bool {
+	return !h.expires.IsZero() || h.idle > 0
+}
+
+// 设置
+// 同Set 额外可通过opts指定entry的ttl、idle timeout
+func (p *LRUCache[K, V]) SetWithOptions(key K, value V, size int, deleter func(key K, value V), opts ...EntryOption) error {
+	h, err := p.Insert_(key, value, size, deleter, opts...)
+	if err != nil {
+		return err
+	}
+	return h.Close()
+}
+
+// 设置后台过期清理的周期
+// interval <= 0 则停止后台清理：过期的entry只会在Lookup或容量压缩时被回收
+func (p *LRUCache[K, V]) SetSweepInterval(interval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopSweeper()
+	p.sweepInterval = interval
+	if interval > 0 && p.expiring {
+		p.startSweeper()
+	}
+}
+
+// 立即清理所有已过期的entry 返回清理的个数
+func (p *LRUCache[K, V]) Sweep() (n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.sweep(time.Now())
+}
+
+// 必须持有锁
+func (p *_LRUCache[K, V]) sweep(now time.Time) (n int) {
+	if !p.expiring {
+		return 0
+	}
+
+	var next *list.Element
+	for element := p.list.Back(); element != nil; element = next {
+		next = element.Prev()
+		if h := element.Value.(*LRUHandle[K, V]); h.Expired(now) {
+			p.removeElement(element, RemovalExpired)
+			p.stats.expirations.Add(1)
+			n++
+		}
+	}
+	return n
+}
+
+// 启动后台清理 必须持有锁
+// goroutine只引用_LRUCache：调用方不再引用LRUCache时 其finalizer仍会Close并停止清理
+func (p *_LRUCache[K, V]) startSweeper() {
+	if p.sweepStop != nil || p.sweepInterval <= 0 || p.closed {
+		return
+	}
+
+	stop := make(chan struct{})
+	p.sweepStop = stop
+	go func(interval time.Duration) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				p.mu.Lock()
+				if !p.closed {
+					p.sweep(now)
+				}
+				p.mu.Unlock()
+			case <-stop:
+				return
+			}
+		}
+	}(p.sweepInterval)
+}
+
+// 停止后台清理 必须持有锁
+func (p *_LRUCache[K, V]) stopSweeper() {
+	if p.sweepStop != nil {
+		close(p.sweepStop)
+		p.sweepStop = nil
+	}
+}
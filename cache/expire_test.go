@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSweepRemovesExpired(t *testing.T) {
+	c, err := NewLRUCache[string, int](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetSweepInterval(5 * time.Millisecond)
+
+	if err := c.SetWithOptions("ttl", 1, 1, nil, WithTTL(20*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetWithOptions("idle", 2, 1, nil, WithIdleTimeout(20*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("forever", 3, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Length() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Length = %d after sweeping, want 1", c.Length())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, ok := c.Get("forever"); !ok {
+		t.Error("entry without expiration was swept")
+	}
+}
+
+// 未Close的cache不再被引用时 后台清理、cache中的handle不能阻止finalizer关闭cache
+func TestSweeperDoesNotPinCache(t *testing.T) {
+	closed := make(chan struct{})
+	func() {
+		c, err := NewLRUCache[string, int](10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetSweepInterval(time.Millisecond)
+		deleter := func(string, int) { close(closed) }
+		if err := c.SetWithOptions("k", 1, 1, deleter, WithTTL(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case <-closed:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("unreferenced cache with a running sweeper was never finalized")
+		}
+	}
+}
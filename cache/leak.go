@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 记录的调用栈最大深度
+const maxLeakStackDepth = 32
+
+// 一次未释放的handle获取
+type acquisition struct {
+	stack string
+	time  time.Time
+}
+
+// 仍被持有的handle
+type OutstandingHandle struct {
+	Key  interface{}
+	Refs int // 除cache自身持有的引用外 调用方仍持有的引用数
+
+	// 以下字段仅在开启泄漏检测(SetLeakDetection)后有值：每次获取的时间及调用栈 按获取顺序排列
+	Acquired []time.Time
+	Stacks   []string
+}
+
+func (h OutstandingHandle) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "key=%v refs=%d", h.Key, h.Refs)
+	for i, stack := range h.Stacks {
+		fmt.Fprintf(&b, "\n  acquired at %v:\n%s", h.Acquired[i].Format(time.RFC3339Nano), strings.TrimRight(stack, "\n"))
+	}
+	return b.String()
+}
+
+// 开启或关闭泄漏检测：开启后记录Insert_、Lookup_、Front、Back、Retain、Take、PopFront、PopBack返回的每个handle的获取调用栈
+// handle被多次获取时 按获取顺序与Close匹配
+// 注：记录调用栈的开销较大 仅用于调试 关闭时丢弃已记录的调用栈
+func (p *LRUCache[K, V]) SetLeakDetection(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !enabled {
+		p.acquisitions = nil
+	} else if p.acquisitions == nil {
+		p.acquisitions = make(map[*LRUHandle[K, V]][]acquisition)
+	}
+}
+
+// 调用方仍持有的handle：包括仍在cache中以及已被Take/Pop取出但未Close的entry
+// 未开启泄漏检测时 只能统计仍在cache中的entry 且不包含调用栈
+func (p *LRUCache[K, V]) OutstandingHandles() []OutstandingHandle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.outstanding()
+}
+
+// 必须持有锁
+func (p *_LRUCache[K, V]) outstanding() (handles []OutstandingHandle) {
+	seen := make(map[*LRUHandle[K, V]]bool)
+	for _, element := range p.table {
+		h := element.Value.(*LRUHandle[K, V])
+		seen[h] = true
+		if refs := atomic.LoadUint32(&h.refs); refs > 1 {
+			handles = append(handles, p.outstandingHandle(h, int(refs)-1))
+		}
+	}
+	for h := range p.acquisitions { // 已离开cache但仍被持有的handle
+		if !seen[h] {
+			handles = append(handles, p.outstandingHandle(h, int(atomic.LoadUint32(&h.refs))))
+		}
+	}
+	return handles
+}
+
+func (p *_LRUCache[K, V]) outstandingHandle(h *LRUHandle[K, V], refs int) OutstandingHandle {
+	o := OutstandingHandle{Key: h.key, Refs: refs}
+	for _, a := range p.acquisitions[h] {
+		o.Acquired = append(o.Acquired, a.time)
+		o.Stacks = append(o.Stacks, a.stack)
+	}
+	return o
+}
+
+// 调用方获取了h的一个引用 必须持有锁
+func (p *_LRUCache[K, V]) acquire(h *LRUHandle[K, V]) {
+	if p.acquisitions == nil {
+		return
+	}
+	pcs := make([]uintptr, maxLeakStackDepth)
+	n := runtime.Callers(3, pcs) // 跳过runtime.Callers、acquire以及cache自身的方法
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "    %s\n        %s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	p.acquisitions[h] = append(p.acquisitions[h], acquisition{stack: b.String(), time: time.Now()})
+}
+
+// 调用方释放了h的一个引用 必须持有锁
+func (p *_LRUCache[K, V]) release(h *LRUHandle[K, V]) {
+	if p.acquisitions == nil {
+		return
+	}
+	if a := p.acquisitions[h]; len(a) > 1 {
+		p.acquisitions[h] = a[1:]
+	} else {
+		delete(p.acquisitions, h)
+	}
+}
+
+// 释放cache内部(Snapshot、Compact等)retain的handle 不影响泄漏检测的记录
+func (p *_LRUCache[K, V]) releaseHandles(handles []*LRUHandle[K, V]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, h := range handles {
+		p.unref(h)
+	}
+}
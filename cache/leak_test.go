@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// 未释放的handle在Close时连同每次获取的调用栈一起报告
+func TestLeakDetectionReportsStacks(t *testing.T) {
+	c, err := NewLRUCache[string, int](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetLeakDetection(true)
+
+	if _, err := c.Insert("leaked", 1, 1, nil); err != nil { // 泄漏
+		t.Fatal(err)
+	}
+	c.Lookup("leaked") // 泄漏第二个引用
+	c.Set("released", 2, 1)
+	_, h, _ := c.Lookup("released")
+	h.Close()
+	c.Set("taken", 3, 1)
+	c.Take("taken") // 已离开cache但仍被持有
+
+	if n := len(c.OutstandingHandles()); n != 2 {
+		t.Fatalf("OutstandingHandles() = %d handles, want 2", n)
+	}
+
+	var leak *HandleLeakError
+	if err := c.Close(); !errors.As(err, &leak) {
+		t.Fatalf("Close = %v, want *HandleLeakError", err)
+	}
+	byKey := map[interface{}]OutstandingHandle{}
+	for _, o := range leak.Handles {
+		byKey[o.Key] = o
+	}
+	if len(byKey) != 2 {
+		t.Fatalf("leaked handles = %v, want leaked and taken", leak.Handles)
+	}
+	for key, refs := range map[string]int{"leaked": 2, "taken": 1} {
+		o, ok := byKey[key]
+		if !ok || o.Refs != refs || len(o.Stacks) != refs || len(o.Acquired) != refs {
+			t.Fatalf("leak for %q = %+v, want %d refs with stacks", key, o, refs)
+		}
+		for _, stack := range o.Stacks {
+			if !strings.Contains(stack, "cache.TestLeakDetectionReportsStacks") || !strings.Contains(stack, "leak_test.go") {
+				t.Errorf("stack for %q does not point at the test:\n%s", key, stack)
+			}
+		}
+	}
+	if msg := leak.Error(); !strings.Contains(msg, "key=leaked refs=2") || !strings.Contains(msg, "acquired at") {
+		t.Errorf("Error() = %q", msg)
+	}
+}
+
+// 未开启泄漏检测时仍报告cache中被持有的entry 但没有调用栈
+func TestLeakWithoutDetection(t *testing.T) {
+	c, err := NewLRUCache[string, int](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Insert("leaked", 1, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var leak *HandleLeakError
+	if err := c.Close(); !errors.As(err, &leak) {
+		t.Fatalf("Close = %v, want *HandleLeakError", err)
+	}
+	if len(leak.Handles) != 1 || leak.Handles[0].Key != "leaked" || leak.Handles[0].Refs != 1 || leak.Handles[0].Stacks != nil {
+		t.Errorf("leaked handles = %+v", leak.Handles)
+	}
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"sync"
 	"container/list"
 	"time"
@@ -11,20 +12,22 @@ import (
 	"io"
 )
 
-var _Cache = (*LRUCache)(nil)
+// LRUCache[string, interface{}]即可直接作为Cache接口使用
+var _ NamespacedCache = (*LRUCache[string, interface{}])(nil)
 
-type LRUCache struct {
-	*_LRUCache
+// 泛型LRU cache：K为key类型 V为value类型，无需再对value做类型断言
+type LRUCache[K comparable, V any] struct {
+	*_LRUCache[K, V]
 }
 
-type _LRUCache struct {
+type _LRUCache[K comparable, V any] struct {
 	// 锁： 控制cache线程安全的保障
 	mu sync.Mutex
 
 	// 双向链表
 	list 	*list.List
 	// hash表映射key-value
-	table 	map[string]*list.Element
+	table 	map[K]*list.Element
 
 	// 当前cache的size
 	size int64
@@ -34,87 +37,155 @@ type _LRUCache struct {
 
 	//
 	last_id uint64
+
+	// 合并同一key的并发getter调用
+	flight flightGroup[K, V]
+
+	// 过期清理：cache中出现过可过期的entry后才启动后台清理
+	expiring		bool
+	sweepInterval	time.Duration
+	sweepStop		chan struct{}
+
+	// 淘汰策略：nil表示使用双向链表本身的顺序(LRU) 见policy.go
+	policy Policy[K, V]
+	// policy为AdmissionPolicy：插入时先加入策略再淘汰 见admit
+	admission bool
+
+	// 命中、淘汰、加载等计数 见stats.go
+	stats counters
+
+	// 移除监听 见removal.go
+	listener RemovalListener[K, V]
+
+	// 快照中value的序列化方式 nil表示gob 见snapshot.go
+	codec Codec[V]
+
+	// 持久化日志 nil表示未开启 见wal.go
+	wal *walLog[K, V]
+
+	// 泄漏检测：调用方持有的handle的获取记录 nil表示未开启 见leak.go
+	acquisitions map[*LRUHandle[K, V]][]acquisition
+
+	// 已关闭
+	closed bool
+
+	// 未遍历完的游标 见cursor.go
+	cursors map[*Cursor[K, V]]struct{}
+
+	// 命名空间的generation 见namespace.go
+	namespaces namespaces
+
+	// size为0时的计算方式 nil表示SizeOf 见sizer.go
+	sizeFunc atomic.Pointer[SizeFunc[V]]
+
+	// 被淘汰或关闭时仍在cache中的entry写入下一级cache 见tiered.go
+	spill func(h *LRUHandle[K, V])
 }
 
 // 包装key-value存在cache【LRUCache】
-type LRUHandle struct {
-	c				*LRUCache
-	key 			string
-	value			interface{}
+type LRUHandle[K comparable, V any] struct {
+	c				*_LRUCache[K, V]  // 不引用LRUCache：否则finalizer所在的LRUCache处于循环引用中 永远不会被回收
+	key 			K
+	value			V
 	size  			int64
-	deleter			func(key string, value interface{})
+	deleter			func(key K, value V)
 	time_created	time.Time
-	time_accessed	atomic.Value
-	refs			uint32
+	time_accessed	atomic.Int64   // UnixNano
+	refs			uint32         // 原子操作
+	accesses		atomic.Int64   // 被Lookup命中的次数
+	merged			int64  // 加载该entry时被合并的getter调用次数
+	expires			time.Time      // 绝对过期时间 IsZero()表示不过期
+	idle			time.Duration  // 空闲有效期 0表示不限制
 }
 
 // ========================================LRUHandle=====================================
 
 //
-func (h *LRUHandle) Key()	string{
+func (h *LRUHandle[K, V]) Key()	K{
 	return h.key
 }
 
 //
-func (h *LRUHandle)	Value() interface{}{
+func (h *LRUHandle[K, V])	Value() V{
 	return h.value
 }
 
 
-func (h *LRUHandle)	Size() int{
+func (h *LRUHandle[K, V])	Size() int{
 	return int(h.size)
 }
 
 
-func (h *LRUHandle) TimeCreated() time.Time{
+func (h *LRUHandle[K, V]) TimeCreated() time.Time{
 	return h.time_created
 }
 
 
-func (h *LRUHandle) Time_Accessed()	time.Time{
-	return h.time_accessed.Load().(time.Time)
+func (h *LRUHandle[K, V]) Time_Accessed()	time.Time{
+	return time.Unix(0, h.time_accessed.Load())
+}
+
+
+// 被Lookup命中的次数
+func (h *LRUHandle[K, V]) Accesses() int64{
+	return h.accesses.Load()
 }
 
 
-func (h *LRUHandle) Retain() (handle *LRUHandle){
+// 加载该entry时被合并的并发GetFrom调用次数
+func (h *LRUHandle[K, V]) Merged() int64{
+	return atomic.LoadInt64(&h.merged)
+}
+
+
+func (h *LRUHandle[K, V]) Retain() (handle *LRUHandle[K, V]){
 	h.c.mu.Lock()
 	defer h.c.mu.Unlock()
 	h.c.addref(h)
+	h.c.acquire(h)
 	return h
 }
 
 //
-func (h *LRUHandle) Close() error{
+func (h *LRUHandle[K, V]) Close() error{
 	h.c.mu.Lock()
 	defer h.c.mu.Unlock()
+	h.c.release(h)
 	h.c.unref(h)
 	return nil
 }
 
 // ========================================LRUCache=====================================
-// 创建LRU cache
-func NewLRUCache(capacity int64) *LRUCache{
-	common.Assert(capacity > 0)
+// 创建LRU cache capacity <= 0时返回ErrInvalidCapacity
+func NewLRUCache[K comparable, V any](capacity int64) (*LRUCache[K, V], error){
+	if err := validateCapacity(capacity); err != nil{
+		return nil, err
+	}
 
-	p := &_LRUCache{
+	p := &_LRUCache[K, V]{
 		list: list.New(),
-		table: make(map[string]*list.Element),
+		table: make(map[K]*list.Element),
 		capacity:	capacity,
+		sweepInterval: DefaultSweepInterval,
 	}
 
-	runtime.SetFinalizer(p, (*_LRUCache).Close)  // 退出清理Cache
-	return &LRUCache{p}
+	// 退出清理Cache：finalizer设置在外层的LRUCache上 内部的handle、游标、后台清理只引用_LRUCache
+	// 调用方不再引用LRUCache时即可被回收 不会因后台清理等而一直存活
+	c := &LRUCache[K, V]{p}
+	runtime.SetFinalizer(c, (*LRUCache[K, V]).Close)
+	return c, nil
 }
 
-// 关闭cache
-func (p *LRUCache) Close() error{
-	runtime.SetFinalizer(p._LRUCache, nil)
-	p._LRUCache.Close()
-	return nil
+// 关闭cache 关闭后cache为空：查询均未命中 插入等修改操作返回ErrCacheClosed
+// 若仍有handle未被释放 返回*HandleLeakError(开启泄漏检测时包含获取的调用栈) cache仍会被关闭：
+// 未释放的handle在最后一次Close时才调用deleter
+func (p *LRUCache[K, V]) Close() error{
+	runtime.SetFinalizer(p, nil)
+	return p._LRUCache.Close()
 }
 
 // 查询
-func (p *LRUCache) Get(key string) (value interface{}, ok bool){
+func (p *LRUCache[K, V]) Get(key K) (value V, ok bool){
 	if v, h, ok := p.Lookup(key); ok{
 		h.Close()
 		return  v, ok
@@ -124,42 +195,110 @@ func (p *LRUCache) Get(key string) (value interface{}, ok bool){
 
 // 若cache中存在 则直接获取
 // 否则通过getter获取 并将获取的内容set到cache
-func (p *LRUCache) GetFrom(key string, getter func(key string) (v interface{} , size int , err error)) (value interface{} , err error){
+// 同一key的并发调用只会执行一次getter：其余调用等待并共享其结果(value或err)
+func (p *LRUCache[K, V]) GetFrom(key K, getter func(key K) (v V, size int, err error)) (value V, err error){
+	if getter == nil{
+		return p.GetFromContext(context.Background(), key, nil)
+	}
+	return p.GetFromContext(context.Background(), key, func(_ context.Context, key K) (V, int, error) {
+		return getter(key)
+	})
+}
+
+// 同GetFrom 不过getter可感知ctx
+// ctx被取消或超时时返回*LoadCanceledError 且不会将任何内容set到cache
+// 多个调用方共享同一次加载时：只有当所有调用方都已离开 才会取消getter的ctx并放弃该次加载
+func (p *LRUCache[K, V]) GetFromContext(ctx context.Context, key K, getter func(ctx context.Context, key K) (v V, size int, err error)) (value V, err error){
 	if v, h, ok := p.Lookup(key); ok{  // cache中存在
 		h.Close()
 		return v, nil
 	}
 
 	if getter == nil{
-		return nil, fmt.Errorf("cache: %q not found!", key)
+		return value, fmt.Errorf("%w: %v", ErrNotFound, key)
+	}
+	if p.Closed(){
+		return value, ErrCacheClosed
+	}
+	if err = ctx.Err(); err != nil{
+		return value, &LoadCanceledError{Key: key, Err: err}
 	}
 
-	value, size, err := getter(key)
-	if err != nil{
+	c, leader := p.flight.join(ctx, key)
+	if leader{  // 由leader发起加载：在独立的goroutine中执行 以便调用方可随时离开
+		go p.load(key, c, getter)
+	}
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		p.flight.leave(key, c)
+		return value, &LoadCanceledError{Key: key, Err: ctx.Err()}
+	}
+}
+
+// 执行getter 并在该次加载未被放弃时将结果set到cache
+func (p *LRUCache[K, V]) load(key K, c *call[V], getter func(ctx context.Context, key K) (v V, size int, err error)) {
+	var h *LRUHandle[K, V]
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil{  // getter panic：转换为error返回给所有等待者
+			c.err = fmt.Errorf("cache: getter of %v panicked: %v", key, r)
+		}
+		p.stats.loads.Add(1)
+		p.stats.loadTime.Add(int64(time.Since(start)))
+		if c.err != nil{
+			p.stats.loadErrors.Add(1)
+		}
+		dups := p.flight.finish(key, c)
+		if h != nil{  // 只有leader的结果会被set到cache
+			atomic.StoreInt64(&h.merged, dups)
+			h.Close()
+		}
+	}()
+
+	c.val, c.size, c.err = getter(c.ctx, key)
+	if c.err != nil || p.flight.complete(key, c){  // 出错或已被所有调用方放弃
 		return
 	}
 
-	common.Assert(size > 0)
-	p.Set(key, value, size)
+	if h, c.err = p.Insert_(key, c.val, c.size, nil); c.err != nil{  // 如getter返回的size < 0
+		var zero V
+		c.val = zero
+	}
+}
 
-	return
+// 查询key对应entry加载时被合并的GetFrom调用次数
+// 若key不存在 则返回 0, false
+func (p *LRUCache[K, V]) MergedCalls(key K) (n int64, ok bool){
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element := p.table[key]
+	if element == nil{
+		return 0, false
+	}
+	return element.Value.(*LRUHandle[K, V]).Merged(), true
 }
 
 // 设置
-func (p *LRUCache) Set(key string, value interface{}, size int, deleter ...func(key string, value interface{})) {
+func (p *LRUCache[K, V]) Set(key K, value V, size int, deleter ...func(key K, value V)) error {
+	var d func(key K, value V)
 	if len(deleter) > 0 {
-		h := p.Insert(key, value, size, deleter[0])
-		h.Close()
-	} else {
-		h := p.Insert(key, value, size, nil)
-		h.Close()
+		d = deleter[0]
 	}
+	h, err := p.Insert_(key, value, size, d)
+	if err != nil {
+		return err
+	}
+	return h.Close()
 }
 
 
 // 获取value
 // 若是cache中没有对应的value，取defaultValue第一个元素作为结果返回
-func (p *LRUCache) Value(key string, defaultValue ...interface{}) interface{}{
+func (p *LRUCache[K, V]) Value(key K, defaultValue ...V) V{
 	if v, h, ok := p.Lookup(key); ok{
 		h.Close()
 		return v
@@ -168,12 +307,13 @@ func (p *LRUCache) Value(key string, defaultValue ...interface{}) interface{}{
 	if len(defaultValue) > 0{
 		return defaultValue[0]
 	} else{
-		return nil
+		var zero V
+		return zero
 	}
 }
 
 //
-func (p *LRUCache) NewId() uint64{
+func (p *LRUCache[K, V]) NewId() uint64{
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -183,27 +323,36 @@ func (p *LRUCache) NewId() uint64{
 
 
 // 插入
-func (p *LRUCache) Insert(key string, value interface{}, size int, deleter func(key string , value interface{})) (handle io.Closer){
-	handle = p.Insert_(key, value, size, deleter)
-	return
+// 注：若需要指定ttl等选项 使用Insert_
+func (p *LRUCache[K, V]) Insert(key K, value V, size int, deleter func(key K, value V)) (io.Closer, error){
+	h, err := p.Insert_(key, value, size, deleter)
+	if err != nil{
+		return nil, err  // 不能返回值为nil的*LRUHandle：io.Closer != nil
+	}
+	return h, nil
 }
 
-func (p *LRUCache) Insert_(key string, value interface{}, size int, deleter func(key string, value interface{})) (handle *LRUHandle){
+// 插入并返回handle
+// size为0时自动计算(见SetSizeFunc)
+// key为空返回ErrEmptyKey size < 0返回ErrInvalidSize size超过capacity返回ErrEntryTooLarge cache已关闭返回ErrCacheClosed
+func (p *LRUCache[K, V]) Insert_(key K, value V, size int, deleter func(key K, value V), opts ...EntryOption) (handle *LRUHandle[K, V], err error){
+	size = resolveSize(&p.sizeFunc, value, size)  // 在锁外计算：估算较大的value可能较慢
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	common.Assert(key != "" && size > 0)
+	if err = p.checkInsert(key, size); err != nil{
+		return nil, err
+	}
 
 	if element := p.table[key]; element != nil{
-		p.list.Remove(element)
-		delete(p.table, key)
-
-		h := element.Value.(*LRUHandle)
-		p.unref(h)
+		p.removeElement(element, RemovalReplaced)
+		p.stats.replacements.Add(1)
 	}
+	p.stats.inserts.Add(1)
 
-	h := &LRUHandle{
-		c:				p,
+	h := &LRUHandle[K, V]{
+		c:				p._LRUCache,
 		key:			key,
 		value:			value,
 		size:			int64(size),
@@ -211,44 +360,69 @@ func (p *LRUCache) Insert_(key string, value interface{}, size int, deleter func
 		time_created: 	time.Now(),
 		refs:			2,  // 1 ---> LRUCache   2 ----> 返回值handle
 	}
-	h.time_accessed.Store(time.Now())
+	h.time_accessed.Store(h.time_created.UnixNano())
+	h.applyOptions(opts)
+	p.acquire(h)
+	if h.expirable(){  // 出现可过期的entry 启动后台清理
+		p.expiring = true
+		p.startSweeper()
+	}
 
 	element := p.list.PushFront(h)   // 最新的数据都在表头
 	p.table[key] = element
 	p.size += h.size
-	p.checkCapacity()                // 添加cache时  需要检查cache的capacity是否已满(size > capacity) 若已满需进行压缩
-	return  h
+	p.admit(h)                       // 添加cache时  需要检查cache的capacity是否已满(size > capacity) 若已满需进行压缩
+	p.walPut(h)
+	return  h, nil
 }
 
 
 // 查询
-func (p *LRUCache) Lookup(key string) (value interface{}, handle io.Closer, ok bool){
+func (p *LRUCache[K, V]) Lookup(key K) (value V, handle io.Closer, ok bool){
 	if v, h, ok := p.Lookup_(key); ok{
 		return v, h, ok
 	}
 	return
 }
 
-func (p *LRUCache) Lookup_(key string) (value interface{}, handle *LRUHandle, ok bool){
+// 查询并返回handle
+func (p *LRUCache[K, V]) Lookup_(key K) (value V, handle *LRUHandle[K, V], ok bool){
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	element := p.table[key]  // 先从二级索引hash table拿数据  若是没有也意味双向链表也没有
 	if element == nil{
-		return nil, nil, false
+		p.stats.misses.Add(1)
+		p.policyMiss(key)
+		return value, nil, false
+	}
+
+	now := time.Now()
+	h := element.Value.(*LRUHandle[K, V])
+	if h.Expired(now){  // 已过期 视为不存在 同时回收
+		p.removeElement(element, RemovalExpired)
+		p.stats.expirations.Add(1)
+		p.stats.misses.Add(1)
+		p.policyMiss(key)
+		return value, nil, false
 	}
+	p.stats.hits.Add(1)
 
 	// 若是存在 则将element放置到表头
-	p.list.MoveToFront(element)
-	h := element.Value.(*LRUHandle)
-	h.time_accessed.Store(time.Now())
+	p.moveToFront(element)
+	if p.policy != nil{
+		p.policy.Access(h)
+	}
+	h.time_accessed.Store(now.UnixNano())
+	h.accesses.Add(1)
 	p.addref(h)
+	p.acquire(h)
 
 	return h.Value(), h, true
 }
 
 // 获取cache中key对应的内容 并删除双向链表和hash table中的记录
-func (p *LRUCache) Take(key string) (handle io.Closer, ok bool){
+func (p *LRUCache[K, V]) Take(key K) (handle io.Closer, ok bool){
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -257,17 +431,14 @@ func (p *LRUCache) Take(key string) (handle io.Closer, ok bool){
 		return nil, false
 	}
 
-	p.list.Remove(element)
-	delete(p.table, key)
-
-	h := element.Value.(*LRUHandle)
-
+	h := p.detachElement(element, RemovalTaken)
+	p.acquire(h)
 	return h, true
 }
 
 
 // 功能很类似Take 额外需要release对应的key关联的handle
-func (p *LRUCache) Erase(key string){
+func (p *LRUCache[K, V]) Erase(key K){
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -276,54 +447,41 @@ func (p *LRUCache) Erase(key string){
 		return
 	}
 
-	p.list.Remove(element)
-	delete(p.table, key)
-
-	h := element.Value.(*LRUHandle)
-	p.unref(h)   // 删除key  需要release关联的handle
-
+	p.removeElement(element, RemovalErased)   // 删除key  需要release关联的handle
+	p.stats.erases.Add(1)
 	return
 }
 
 
-// 设置cache的capacity
-func (p *LRUCache) SetCapacity(capacity int64){
+// 设置cache的capacity capacity <= 0时返回ErrInvalidCapacity
+func (p *LRUCache[K, V]) SetCapacity(capacity int64) error{
+	if err := validateCapacity(capacity); err != nil{
+		return err
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	common.Assert(capacity > 0)
 	p.capacity = capacity
+	p.policyCapacity()
 	p.checkCapacity()  // 检查size 是否超过capacity
-
+	return nil
 }
 
 
 // 统计信息cache
-func (p *LRUCache) Stats() (length, size, capacity int64, oldest time.Time){
+func (p *LRUCache[K, V]) Stats() (length, size, capacity int64, oldest time.Time){
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if lastElem := p.list.Back(); lastElem != nil{
-		oldest = lastElem.Value.(*LRUHandle).time_accessed.Load().(time.Time)
+		oldest = lastElem.Value.(*LRUHandle[K, V]).Time_Accessed()
 	}
 	return int64(p.list.Len()), p.size, p.capacity, oldest
 }
-// 统计信息json格式
-func (p *LRUCache) StatsJSON() string {
-	if p == nil {
-		return "{}"
-	}
-	l, s, c, o := p.Stats()
-	return fmt.Sprintf(`{
-	"Length": %v,
-	"Size": %v,
-	"Capacity": %v,
-	"OldestAccess": "%v"
-}`, l, s, c, o)
-}
 
 // cache中element的个数
-func (p *LRUCache) Length() int64{
+func (p *LRUCache[K, V]) Length() int64{
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -331,14 +489,14 @@ func (p *LRUCache) Length() int64{
 }
 
 //
-func (p *LRUCache) Size() int64{
+func (p *LRUCache[K, V]) Size() int64{
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	return p.size
 }
 
-func (p *LRUCache) Capacity() int64{
+func (p *LRUCache[K, V]) Capacity() int64{
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -347,76 +505,139 @@ func (p *LRUCache) Capacity() int64{
 
 // cache中最新element对应的时间
 // 若是cache不存在 则返回IsZero() time
-func (p *LRUCache) Newest() (newest time.Time) {
+func (p *LRUCache[K, V]) Newest() (newest time.Time) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if frontElem := p.list.Front(); frontElem != nil {
-		newest = frontElem.Value.(*LRUHandle).time_accessed.Load().(time.Time)
+		newest = frontElem.Value.(*LRUHandle[K, V]).Time_Accessed()
 	}
 	return
 }
 
-func (p *LRUCache) Oldest() (oldest time.Time) {
+func (p *LRUCache[K, V]) Oldest() (oldest time.Time) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if lastElem := p.list.Back(); lastElem != nil {
-		oldest = lastElem.Value.(*LRUHandle).time_accessed.Load().(time.Time)
+		oldest = lastElem.Value.(*LRUHandle[K, V]).Time_Accessed()
 	}
 	return
 }
 
 
 // cache中所有的keys； 按照使用时间的最近进行排序
-func (p *LRUCache) Keys() []string {
+func (p *LRUCache[K, V]) Keys() []K {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	keys := make([]string, 0, p.list.Len())
+	keys := make([]K, 0, p.list.Len())
 	for e := p.list.Front(); e != nil; e = e.Next() {
-		keys = append(keys, e.Value.(*LRUHandle).key)
+		keys = append(keys, e.Value.(*LRUHandle[K, V]).key)
 	}
 	return keys
 }
 
 // 清除cache
 // 前提要release所有key关联的handle
-func (p *LRUCache) Clear() {
+func (p *LRUCache[K, V]) Clear() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.clear()
+}
+
+// 必须持有锁
+func (p *_LRUCache[K, V]) clear() {
+	p.finishCursors()
+	p.walReset()
 	for _, element := range p.table {
-		h := element.Value.(*LRUHandle)
+		h := element.Value.(*LRUHandle[K, V])
+		if p.policy != nil {
+			p.policy.Remove(h)
+		}
+		p.notifyRemoval(h, RemovalCleared)
 		p.unref(h)
 	}
 
 	p.list = list.New()
-	p.table = make(map[string]*list.Element)
-	p.size = 0
+	p.table = make(map[K]*list.Element)  // size由unref扣减：调用方仍持有的handle释放后才扣减 与Erase相同
 	return
 }
 
 
 // 检查cache的size是否已经超过capacity
 // 一旦超过了 则进行收缩： 淘汰旧数据 直至size <= capacity
-func (p *LRUCache) checkCapacity() {
+// 设置了淘汰策略时由策略选择淘汰的entry
+func (p *LRUCache[K, V]) checkCapacity() {
 	for p.size > p.capacity && len(p.table) > 1 {
-		delElem := p.list.Back()
-		h := delElem.Value.(*LRUHandle)
-		p.list.Remove(delElem)
-		delete(p.table, h.key)
-		p.unref(h)
+		victim := p.list.Back()
+		if p.policy != nil {
+			h := p.policy.Victim()
+			if h == nil {
+				return
+			}
+			victim = p.table[h.key]
+		}
+		p.removeElement(victim, RemovalEvicted)
+		p.stats.evictions.Add(1)
+	}
+}
+
+// 新的entry加入淘汰策略并检查capacity
+// 准入策略先加入再淘汰：新entry作为候选者与victim比较 可能被立即淘汰
+// 其他策略先淘汰再加入：新插入的entry不会被立即淘汰
+func (p *LRUCache[K, V]) admit(h *LRUHandle[K, V]) {
+	if p.admission {
+		p.policyInsert(h)
+		p.checkCapacity()
+		return
 	}
+	p.checkCapacity()
+	p.policyInsert(h)
+}
+
+// 新的entry加入淘汰策略
+func (p *_LRUCache[K, V]) policyInsert(h *LRUHandle[K, V]) {
+	if p.policy != nil {
+		p.policy.Insert(h)
+	}
+}
+
+// 从双向链表和hash table中移除element 不release handle
+func (p *_LRUCache[K, V]) detachElement(element *list.Element, reason RemovalReason) (h *LRUHandle[K, V]) {
+	h = element.Value.(*LRUHandle[K, V])
+	p.unanchor(element)
+	p.list.Remove(element)
+	delete(p.table, h.key)
+	if p.policy != nil {
+		p.policy.Remove(h)
+	}
+	p.notifyRemoval(h, reason)
+	return h
+}
+
+// 从双向链表和hash table中移除element 并release cache持有的handle
+func (p *_LRUCache[K, V]) removeElement(element *list.Element, reason RemovalReason) {
+	p.unref(p.detachElement(element, reason))
+}
+
+// 空key校验：仅对string类型的key有意义
+func isEmptyKey[K comparable](key K) bool {
+	s, ok := any(key).(string)
+	return ok && s == ""
 }
 
-func (p *_LRUCache) addref(h *LRUHandle) {
-	h.refs++
+func (p *_LRUCache[K, V]) addref(h *LRUHandle[K, V]) {
+	atomic.AddUint32(&h.refs, 1)
 }
 
-func (p *_LRUCache) unref(h *LRUHandle) {
-	common.Assert(h.refs > 0)
-	h.refs--
-	if h.refs <= 0 {
-		p.size -= h.size
+// 必须持有锁：最后一个引用释放时更新size并调用deleter
+func (p *_LRUCache[K, V]) unref(h *LRUHandle[K, V]) {
+	refs := atomic.AddUint32(&h.refs, ^uint32(0))
+	common.Assert(refs != ^uint32(0))
+	if refs == 0 {
+		if !p.closed { // Close已将size清零：之后释放的handle不再扣减
+			p.size -= h.size
+		}
 		if h.deleter != nil {
 			h.deleter(h.key, h.value)
 		}
@@ -424,26 +645,58 @@ func (p *_LRUCache) unref(h *LRUHandle) {
 }
 
 //==========================================实现io.Closer==========================================
-func (p *_LRUCache) Close() {
+func (p *_LRUCache[K, V]) Close() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.closed {
+		return nil
+	}
+	p.stopSweeper()
+	p.closeWAL()
+	p.finishCursors()
+
+	var err error
+	if leaks := p.outstanding(); len(leaks) > 0 {
+		err = &HandleLeakError{Handles: leaks}
+	}
 	for _, element := range p.table {
-		h := element.Value.(*LRUHandle)
-		common.Assert(h.refs == 1, "h.refs = ", h.refs)
+		h := element.Value.(*LRUHandle[K, V])
+		p.notifyRemoval(h, RemovalClosed)
 		p.unref(h)
 	}
 
-	p.list = nil
-	p.table = nil
+	// 关闭后保留空的list和table：查询等操作无需额外判断
+	p.closed = true
+	p.list = list.New()
+	p.table = make(map[K]*list.Element)
 	p.size = 0
+	p.storePolicy(nil)
+	p.acquisitions = nil
+	return err
+}
+
+// 插入前的检查 必须持有锁
+func (p *_LRUCache[K, V]) checkInsert(key K, size int) error {
+	if p.closed {
+		return ErrCacheClosed
+	}
+	return validateEntry(key, size, p.capacity)
+}
+
+// cache是否已关闭
+func (p *LRUCache[K, V]) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.closed
 }
 
 
 //==========================================cache lur实现扩展==========================================
 
 // 查询二级索引hash表判断对应的key是否存在
-func (p *LRUCache) HashKey(key string) bool{
+func (p *LRUCache[K, V]) HashKey(key K) bool{
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -453,29 +706,29 @@ func (p *LRUCache) HashKey(key string) bool{
 
 // 先获取双向链表表头的element 拿到关联的handle
 // 接着从handle拿到key
-func (p *LRUCache) FrontKey() (key string){
+func (p *LRUCache[K, V]) FrontKey() (key K){
 	if h := p.Front(); h != nil{
 		key = h.Key()
 		h.Close()
 
 		return key
 	}
-	return ""
+	return
 }
 
 //同FrontKey
-func (p *LRUCache) BackKey() (key string) {
+func (p *LRUCache[K, V]) BackKey() (key K) {
 	if h := p.Back(); h != nil {
 		key = h.Key()
 		h.Close()
 		return key
 	}
-	return ""
+	return
 }
 
 // 同FrontKey 先拿到双向链表表头element的handle
 // 不过当handle没有内容时，则可使用defaultvalues[0]作为结果
-func (p *LRUCache) FrontValue(defaultValue ...interface{}) (value interface{}) {
+func (p *LRUCache[K, V]) FrontValue(defaultValue ...V) (value V) {
 	if h := p.Front(); h != nil {
 		value = h.Value()
 		h.Close()
@@ -484,12 +737,12 @@ func (p *LRUCache) FrontValue(defaultValue ...interface{}) (value interface{}) {
 	if len(defaultValue) > 0 {
 		return defaultValue[0]
 	} else {
-		return nil
+		return
 	}
 }
 
 // 同FrontValue
-func (p *LRUCache) BackValue(defaultValue ...interface{}) (value interface{}) {
+func (p *LRUCache[K, V]) BackValue(defaultValue ...V) (value V) {
 	if h := p.Back(); h != nil {
 		value = h.Value()
 		h.Close()
@@ -498,26 +751,34 @@ func (p *LRUCache) BackValue(defaultValue ...interface{}) (value interface{}) {
 	if len(defaultValue) > 0 {
 		return defaultValue[0]
 	} else {
-		return nil
+		return
 	}
 }
 
 // 移除双向链表表头
-func (p *LRUCache) RemoveFront() {
-	if h := p.PopFront(); h != nil {
-		h.Close()
+func (p *LRUCache[K, V]) RemoveFront() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element := p.list.Front(); element != nil {
+		p.removeElement(element, RemovalErased)
+		p.stats.erases.Add(1)
 	}
 }
 
 // 移除双向链表表尾
-func (p *LRUCache) RemoveBack() {
-	if h := p.PopBack(); h != nil {
-		h.Close()
+func (p *LRUCache[K, V]) RemoveBack() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element := p.list.Back(); element != nil {
+		p.removeElement(element, RemovalErased)
+		p.stats.erases.Add(1)
 	}
 }
 
 // 先获取双向列表表头的element  获取到关联的handle【LRUHandle类似redis里面的redisobject】
-func (p *LRUCache) Front() (h *LRUHandle) {
+func (p *LRUCache[K, V]) Front() (h *LRUHandle[K, V]) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -526,13 +787,14 @@ func (p *LRUCache) Front() (h *LRUHandle) {
 		return
 	}
 
-	h = element.Value.(*LRUHandle)
+	h = element.Value.(*LRUHandle[K, V])
 	p.addref(h)   // 使用handle 一定要增加ref数
+	p.acquire(h)
 	return
 }
 
 // 同Front
-func (p *LRUCache) Back() (h *LRUHandle) {
+func (p *LRUCache[K, V]) Back() (h *LRUHandle[K, V]) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -541,27 +803,30 @@ func (p *LRUCache) Back() (h *LRUHandle) {
 		return
 	}
 
-	h = element.Value.(*LRUHandle)
+	h = element.Value.(*LRUHandle[K, V])
 	p.addref(h)
+	p.acquire(h)
 	return
 }
 
 // 将element压入到表头
-func (p *LRUCache) PushFront(key string, value interface{}, size int, deleter func(key string, value interface{})) {
+func (p *LRUCache[K, V]) PushFront(key K, value V, size int, deleter func(key K, value V)) error {
+	size = resolveSize(&p.sizeFunc, value, size)
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	common.Assert(key != "" && size > 0)
+	if err := p.checkInsert(key, size); err != nil {
+		return err
+	}
 	if element := p.table[key]; element != nil {   // 添加element已存在，则需要指定清理操作：双向链表remove  二级索引table delete
-		p.list.Remove(element)
-		delete(p.table, key)
-
-		h := element.Value.(*LRUHandle)
-		p.unref(h)
+		p.removeElement(element, RemovalReplaced)
+		p.stats.replacements.Add(1)
 	}
+	p.stats.inserts.Add(1)
 
-	h := &LRUHandle{
-		c:            p,
+	h := &LRUHandle[K, V]{
+		c:            p._LRUCache,
 		key:          key,
 		value:        value,
 		size:         int64(size),
@@ -569,31 +834,35 @@ func (p *LRUCache) PushFront(key string, value interface{}, size int, deleter fu
 		time_created: time.Now(),
 		refs:         1, // 添加element至少会产生一个ref【此处没有返回handle 故而只有一个ref】
 	}
-	h.time_accessed.Store(time.Now())
+	h.time_accessed.Store(h.time_created.UnixNano())
 
 	element := p.list.PushFront(h)
 	p.table[key] = element
 	p.size += h.size
-	p.checkCapacity()
-	return
+	p.admit(h)
+	p.walPut(h)
+	return nil
 }
 
 // 同PushFront：将element压入到双向链表的表尾
-func (p *LRUCache) PushBack(key string, value interface{}, size int, deleter func(key string, value interface{})) {
+// 注：设置了淘汰策略(SetPolicy)时 只影响双向链表的顺序 不影响淘汰顺序
+func (p *LRUCache[K, V]) PushBack(key K, value V, size int, deleter func(key K, value V)) error {
+	size = resolveSize(&p.sizeFunc, value, size)
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	common.Assert(key != "" && size > 0)
+	if err := p.checkInsert(key, size); err != nil {
+		return err
+	}
 	if element := p.table[key]; element != nil {
-		p.list.Remove(element)
-		delete(p.table, key)
-
-		h := element.Value.(*LRUHandle)
-		p.unref(h)
+		p.removeElement(element, RemovalReplaced)
+		p.stats.replacements.Add(1)
 	}
+	p.stats.inserts.Add(1)
 
-	h := &LRUHandle{
-		c:            p,
+	h := &LRUHandle[K, V]{
+		c:            p._LRUCache,
 		key:          key,
 		value:        value,
 		size:         int64(size),
@@ -601,17 +870,18 @@ func (p *LRUCache) PushBack(key string, value interface{}, size int, deleter fun
 		time_created: time.Now(),
 		refs:         1, //添加element至少会产生一个ref【此处没有返回handle 故而只有一个ref】
 	}
-	h.time_accessed.Store(time.Now())
+	h.time_accessed.Store(h.time_created.UnixNano())
 
 	element := p.list.PushBack(h)
 	p.table[key] = element
 	p.size += h.size
-	p.checkCapacity()
-	return
+	p.admit(h)
+	p.walPut(h)
+	return nil
 }
 
 // 弹出双向链表尾element
-func (p *LRUCache) PopBack() (h *LRUHandle) {
+func (p *LRUCache[K, V]) PopBack() (h *LRUHandle[K, V]) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -620,14 +890,13 @@ func (p *LRUCache) PopBack() (h *LRUHandle) {
 		return
 	}
 
-	h = element.Value.(*LRUHandle)
-	delete(p.table, h.Key())
-	p.list.Remove(element)
+	h = p.detachElement(element, RemovalTaken)
+	p.acquire(h)
 	return
 }
 
 // 弹出双向链表=头element
-func (p *LRUCache) PopFront() (h *LRUHandle) {
+func (p *LRUCache[K, V]) PopFront() (h *LRUHandle[K, V]) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -636,14 +905,13 @@ func (p *LRUCache) PopFront() (h *LRUHandle) {
 		return
 	}
 
-	h = element.Value.(*LRUHandle)
-	delete(p.table, h.Key())
-	p.list.Remove(element)
+	h = p.detachElement(element, RemovalTaken)
+	p.acquire(h)
 	return
 }
 
 // 移动到双向链表表头
-func (p *LRUCache) MoveToFront(key string) {
+func (p *LRUCache[K, V]) MoveToFront(key K) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -652,12 +920,13 @@ func (p *LRUCache) MoveToFront(key string) {
 		return
 	}
 
-	p.list.MoveToFront(element)
+	p.moveToFront(element)
 	return
 }
 
 // 移动到双向链表表尾
-func (p *LRUCache) MoveToBack(key string) {
+// 注：设置了淘汰策略(SetPolicy)时 只影响双向链表的顺序 不影响淘汰顺序
+func (p *LRUCache[K, V]) MoveToBack(key K) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -666,17 +935,9 @@ func (p *LRUCache) MoveToBack(key string) {
 		return
 	}
 
-	p.list.MoveToBack(element)
+	if p.list.Back() != element {
+		p.unanchor(element)
+		p.list.MoveToBack(element)
+	}
 	return
 }
-
-
-
-
-
-
-
-
-
-
-
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Lookup与并发的Erase、Set、Close：go test -race
+// 每个命中的handle都能读取到value 所有handle释放后size及deleter的调用次数与entry一致
+func TestConcurrentLookupEraseClose(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		c, err := NewLRUCache[int, int](64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var inserted, deleted atomic.Int64
+		deleter := func(key, value int) {
+			if key != value {
+				t.Errorf("deleter(%d, %d): key and value differ", key, value)
+			}
+			deleted.Add(1)
+		}
+		set := func(k int) {
+			if h, err := c.Insert(k, k, 1, deleter); err == nil {
+				inserted.Add(1)
+				h.Close()
+			}
+		}
+		for k := 0; k < 32; k++ {
+			set(k)
+		}
+
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(seed uint64) {
+				defer wg.Done()
+				r := rand.New(rand.NewPCG(seed, seed))
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					k := r.IntN(32)
+					if v, h, ok := c.Lookup_(k); ok {
+						if v != k || h.Value() != k {
+							t.Errorf("Lookup_(%d) = %d", k, v)
+						}
+						h.Close()
+					}
+				}
+			}(uint64(g))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				k := i % 32
+				if i%2 == 0 {
+					c.Erase(k)
+				} else {
+					set(k)
+				}
+			}
+		}()
+		time.Sleep(time.Millisecond)
+		c.Close() // 与查询并发
+		close(stop)
+		wg.Wait()
+
+		if got := c.Size(); got != 0 {
+			t.Fatalf("Size after Close = %d, want 0", got)
+		}
+		if inserted.Load() != deleted.Load() {
+			t.Fatalf("deleter called %d times for %d inserted entries", deleted.Load(), inserted.Load())
+		}
+	}
+}
+
+// Clear后仍被持有的handle释放时才扣减size；Close后释放的handle不再扣减
+func TestReleaseAfterClearAndClose(t *testing.T) {
+	c, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := c.Insert("a", 1, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("b", 2, 5)
+	_, cleared, _ := c.Lookup("b")
+	c.Clear()
+	if got := c.Size(); got != 15 {
+		t.Fatalf("Size after Clear = %d, want 15", got)
+	}
+	cleared.Close()
+	h.Close()
+	if got := c.Size(); got != 0 {
+		t.Fatalf("Size after releasing cleared handles = %d, want 0", got)
+	}
+
+	h, err = c.Insert("c", 3, 7, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("d", 4, 3)
+	_, erased, _ := c.Lookup("d")
+	c.Erase("d")
+	var leak *HandleLeakError
+	if err := c.Close(); !errors.As(err, &leak) {
+		t.Fatalf("Close = %v, want *HandleLeakError", err)
+	}
+	h.Close()
+	erased.Close()
+	if got := c.Size(); got != 0 {
+		t.Errorf("Size after releasing leaked handles = %d, want 0", got)
+	}
+}
+
+// 非string的key及value类型：deleter在entry被删除且所有handle释放后调用 参数为原始的key、value
+func TestGenericIntKeys(t *testing.T) {
+	type point struct{ X, Y int }
+	c, err := NewLRUCache[int, point](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := map[int]point{}
+	deleter := func(key int, value point) { deleted[key] = value }
+
+	for i := 1; i <= 3; i++ {
+		h, err := c.Insert(i, point{i, -i}, 1, deleter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Close()
+	}
+	v, h, ok := c.Lookup_(2)
+	if !ok || v != (point{2, -2}) || h.Key() != 2 || h.Value() != v {
+		t.Fatalf("Lookup_(2) = %v, %v, %v", v, h, ok)
+	}
+
+	c.Erase(2) // 仍被h持有
+	if _, ok := deleted[2]; ok {
+		t.Fatal("deleter called while a handle is held")
+	}
+	if _, _, ok := c.Lookup(2); ok {
+		t.Fatal("Lookup(2) hit after Erase")
+	}
+	h.Close()
+	if deleted[2] != (point{2, -2}) {
+		t.Fatalf("deleter after release: %v", deleted)
+	}
+
+	c.Set(1, point{10, 10}, 1, deleter) // 替换
+	if deleted[1] != (point{1, -1}) {
+		t.Fatalf("deleter after replace: %v", deleted)
+	}
+	c.Set(4, point{4, 4}, 1, deleter)
+	c.Set(5, point{5, 5}, 1, deleter) // 淘汰最久未使用的3
+	if deleted[3] != (point{3, -3}) {
+		t.Fatalf("deleter after eviction: %v", deleted)
+	}
+	if keys := c.Keys(); fmt.Sprint(keys) != "[5 4 1]" {
+		t.Errorf("Keys() = %v, want [5 4 1]", keys)
+	}
+
+	clear(deleted)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 3 || deleted[1] != (point{10, 10}) {
+		t.Errorf("deleter after Close: %v", deleted)
+	}
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// 命名空间：多个client共享同一个cache时 为每个client(通常使用NewId分配的id)划分独立的key空间
+// 视图中的key自动加上"id_generation_"前缀
+// InvalidateNamespace使命名空间的generation加1：之后的查询均使用新的前缀 旧的entry立即全部未命中
+// 旧的entry不会被立即删除 而是作为最久未使用的entry被正常淘汰(其deleter照常被调用)
+// 注：旧的entry在被淘汰前仍占用cache的容量
+type namespaces struct {
+	mu   sync.Mutex
+	gens map[uint64]uint64
+}
+
+// 命名空间当前generation的key前缀
+func (n *namespaces) prefix(id uint64) string {
+	n.mu.Lock()
+	gen := n.gens[id]
+	n.mu.Unlock()
+
+	return strconv.FormatUint(id, 10) + "_" + strconv.FormatUint(gen, 10) + "_"
+}
+
+func (n *namespaces) invalidate(id uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.gens == nil {
+		n.gens = make(map[uint64]uint64)
+	}
+	n.gens[id]++
+}
+
+// 命名空间视图：实现NamespacedCache接口 所有操作作用于底层cache中带前缀的key
+type namespace struct {
+	c  Cache
+	ns *namespaces
+	id uint64
+
+	children namespaces // 视图本身的子命名空间
+	closed   atomic.Bool
+}
+
+var _ NamespacedCache = (*namespace)(nil)
+
+// c不是Cache(即K不为string或V不为interface{})时返回ErrNamespaceUnsupported
+func newNamespace(c interface{}, ns *namespaces, id uint64) (NamespacedCache, error) {
+	cc, ok := c.(Cache)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNamespaceUnsupported, c)
+	}
+	return &namespace{c: cc, ns: ns, id: id}, nil
+}
+
+func (n *namespace) NewId() uint64 {
+	return n.c.NewId()
+}
+
+// deleter收到的是视图中的key(不含前缀)
+func (n *namespace) Insert(key string, value interface{}, size int, deleter func(key string, value interface{})) (handle io.Closer, err error) {
+	if n.closed.Load() {
+		return nil, ErrCacheClosed
+	}
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	prefix := n.ns.prefix(n.id)
+	if deleter != nil {
+		d := deleter
+		deleter = func(key string, value interface{}) {
+			d(key[len(prefix):], value)
+		}
+	}
+	return n.c.Insert(prefix+key, value, size, deleter)
+}
+
+func (n *namespace) Lookup(key string) (value interface{}, handle io.Closer, ok bool) {
+	if n.closed.Load() {
+		return
+	}
+	return n.c.Lookup(n.ns.prefix(n.id) + key)
+}
+
+func (n *namespace) Erase(key string) {
+	if n.closed.Load() {
+		return
+	}
+	n.c.Erase(n.ns.prefix(n.id) + key)
+}
+
+// 释放视图：之后的操作返回ErrCacheClosed或未命中
+// 命名空间中的entry及其他视图不受影响 使其失效需调用InvalidateNamespace
+func (n *namespace) Close() error {
+	n.closed.Store(true)
+	return nil
+}
+
+// 子命名空间：其key空间包含在本命名空间之内 本命名空间失效时子命名空间同时失效
+func (n *namespace) Namespace(id uint64) (NamespacedCache, error) {
+	if n.closed.Load() {
+		return nil, ErrCacheClosed
+	}
+	return newNamespace(n, &n.children, id)
+}
+
+func (n *namespace) InvalidateNamespace(id uint64) {
+	n.children.invalidate(id)
+}
+
+// ========================================各cache的命名空间=====================================
+
+// 命名空间视图
+// 注：视图基于Cache接口 仅当K为string且V为interface{}(即作为Cache使用)时可用 否则返回ErrNamespaceUnsupported
+func (p *LRUCache[K, V]) Namespace(id uint64) (NamespacedCache, error) {
+	return newNamespace(p, &p.namespaces, id)
+}
+
+// 使命名空间中的所有entry失效
+func (p *LRUCache[K, V]) InvalidateNamespace(id uint64) {
+	p.namespaces.invalidate(id)
+}
+
+// 命名空间视图 约定同LRUCache.Namespace
+func (p *ARCCache[K, V]) Namespace(id uint64) (NamespacedCache, error) {
+	return newNamespace(p, &p.namespaces, id)
+}
+
+// 使命名空间中的所有entry失效
+func (p *ARCCache[K, V]) InvalidateNamespace(id uint64) {
+	p.namespaces.invalidate(id)
+}
+
+// 命名空间视图：generation由所有分片共享
+func (p *ShardedLRUCache) Namespace(id uint64) (NamespacedCache, error) {
+	return newNamespace(p, &p.namespaces, id)
+}
+
+// 使命名空间中的所有entry失效
+func (p *ShardedLRUCache) InvalidateNamespace(id uint64) {
+	p.namespaces.invalidate(id)
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	c, err := NewLRUCache[string, interface{}](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ns1, err := c.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns2, err := c.Namespace(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ns, v := range map[NamespacedCache]string{ns1: "v1", ns2: "v2"} {
+		h, err := ns.Insert("k", v, 1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Close()
+	}
+	if v, h, ok := ns1.Lookup("k"); !ok || v != "v1" {
+		t.Fatalf("ns1.Lookup = %v, %v, want v1", v, ok)
+	} else {
+		h.Close()
+	}
+
+	c.InvalidateNamespace(1)
+	if _, _, ok := ns1.Lookup("k"); ok {
+		t.Error("ns1 entry visible after InvalidateNamespace")
+	}
+	if _, h, ok := ns2.Lookup("k"); !ok {
+		t.Error("ns2 entry invalidated with ns1")
+	} else {
+		h.Close()
+	}
+}
+
+// 视图的Close只释放视图本身
+func TestNamespaceClose(t *testing.T) {
+	c, err := NewLRUCache[string, interface{}](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ns, err := c.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := ns.Insert("k", "v", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	if err := ns.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ns.Insert("k2", "v", 1, nil); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("Insert on closed view err = %v, want ErrCacheClosed", err)
+	}
+	if _, _, ok := ns.Lookup("k"); ok {
+		t.Error("Lookup on closed view hit")
+	}
+	if _, err := ns.Namespace(2); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("Namespace on closed view err = %v, want ErrCacheClosed", err)
+	}
+
+	other, err := c.Namespace(1) // 命名空间本身未失效
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, h, ok := other.Lookup("k"); !ok || v != "v" {
+		t.Errorf("Lookup from new view = %v, %v, want v, true", v, ok)
+	} else {
+		h.Close()
+	}
+	if c.Closed() {
+		t.Error("closing a view closed the cache")
+	}
+}
+
+func TestNamespaceUnsupported(t *testing.T) {
+	c, err := NewLRUCache[int, string](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if ns, err := c.Namespace(1); !errors.Is(err, ErrNamespaceUnsupported) || ns != nil {
+		t.Errorf("Namespace on LRUCache[int, string] = %v, %v, want nil, ErrNamespaceUnsupported", ns, err)
+	}
+}
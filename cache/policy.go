@@ -0,0 +1,286 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"math/rand"
+)
+
+// 淘汰策略：决定cache容量不足时淘汰哪个entry
+// LRUCache在entry插入、命中、移出时回调对应的方法 并在需要淘汰时调用Victim
+// handle的引用计数以及deleter仍由LRUCache负责：Policy只负责决定淘汰顺序
+// 注：所有方法都在LRUCache持有锁时被调用 Policy自身无需加锁 但不能回调LRUCache的方法
+type Policy[K comparable, V any] interface {
+	// 新的entry插入cache
+	Insert(h *LRUHandle[K, V])
+
+	// entry被Lookup命中
+	Access(h *LRUHandle[K, V])
+
+	// entry被移出cache：淘汰、删除、替换、Take、Clear等
+	Remove(h *LRUHandle[K, V])
+
+	// 选择下一个淘汰的entry 不会将其移除(由随后的Remove回调移除)
+	// 若策略中没有entry则返回nil
+	Victim() *LRUHandle[K, V]
+}
+
+// 可选接口：准入策略 如W-TinyLFU
+// Lookup未命中时回调RecordMiss：不在cache中的key的访问同样计入频率
+// 插入时先回调Insert再淘汰：新entry作为候选者参与比较 Victim可以返回新插入的entry(拒绝准入)
+// 未实现该接口的Policy在淘汰之后才收到Insert：新插入的entry不会被立即淘汰
+type AdmissionPolicy[K comparable, V any] interface {
+	Policy[K, V]
+
+	// key被Lookup且不在cache中(包括已过期)
+	RecordMiss(key K)
+}
+
+// 可选接口：依赖cache容量的策略 如SLRU的protected段容量
+// 设置策略时(插入已存在的entry之前)以及SetCapacity时(淘汰之前)回调
+type CapacityPolicy[K comparable, V any] interface {
+	Policy[K, V]
+
+	// cache的capacity
+	SetCapacity(capacity int64)
+}
+
+// 设置cache的淘汰策略
+// cache中已存在的entry按照从旧到新的顺序插入新的策略
+// policy为nil时使用双向链表本身的顺序(LRU)：PushBack、MoveToBack等方法会直接影响淘汰顺序
+// 注：设置了policy后 PushFront/PushBack/MoveToFront/MoveToBack只影响双向链表(Keys、Front、Back)的顺序
+func (p *LRUCache[K, V]) SetPolicy(policy Policy[K, V]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.setPolicy(policy)
+}
+
+// 必须持有锁
+func (p *_LRUCache[K, V]) setPolicy(policy Policy[K, V]) {
+	p.storePolicy(policy)
+	if policy == nil {
+		return
+	}
+	p.policyCapacity()
+	for element := p.list.Back(); element != nil; element = element.Prev() {
+		policy.Insert(element.Value.(*LRUHandle[K, V]))
+	}
+}
+
+// 替换p.policy 不迁移entry 必须持有锁
+func (p *_LRUCache[K, V]) storePolicy(policy Policy[K, V]) {
+	p.policy = policy
+	_, ok := policy.(AdmissionPolicy[K, V])
+	p.admission = ok
+}
+
+// 通知策略cache的capacity 必须持有锁
+func (p *_LRUCache[K, V]) policyCapacity() {
+	if c, ok := p.policy.(CapacityPolicy[K, V]); ok {
+		c.SetCapacity(p.capacity)
+	}
+}
+
+// 未命中的key通知准入策略 必须持有锁
+func (p *_LRUCache[K, V]) policyMiss(key K) {
+	if a, ok := p.policy.(AdmissionPolicy[K, V]); ok {
+		a.RecordMiss(key)
+	}
+}
+
+// cache的淘汰策略 nil表示使用双向链表本身的顺序(LRU)
+func (p *LRUCache[K, V]) Policy() Policy[K, V] {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.policy
+}
+
+// ========================================LRU/MRU/FIFO=====================================
+
+// 基于双向链表的策略：表头为最近插入(或访问)的entry
+type listPolicy[K comparable, V any] struct {
+	list     *list.List
+	elements map[*LRUHandle[K, V]]*list.Element
+	touch    bool // 命中时是否移动到表头
+	newest   bool // 淘汰表头(最新)还是表尾(最旧)
+}
+
+func newListPolicy[K comparable, V any](touch, newest bool) *listPolicy[K, V] {
+	return &listPolicy[K, V]{
+		list:     list.New(),
+		elements: make(map[*LRUHandle[K, V]]*list.Element),
+		touch:    touch,
+		newest:   newest,
+	}
+}
+
+// LRU：淘汰最久未被访问的entry
+func NewLRUPolicy[K comparable, V any]() Policy[K, V] {
+	return newListPolicy[K, V](true, false)
+}
+
+// MRU：淘汰最近被访问的entry 适合循环扫描的访问模式
+func NewMRUPolicy[K comparable, V any]() Policy[K, V] {
+	return newListPolicy[K, V](true, true)
+}
+
+// FIFO：按照插入顺序淘汰 访问不影响淘汰顺序
+func NewFIFOPolicy[K comparable, V any]() Policy[K, V] {
+	return newListPolicy[K, V](false, false)
+}
+
+func (l *listPolicy[K, V]) Insert(h *LRUHandle[K, V]) {
+	l.elements[h] = l.list.PushFront(h)
+}
+
+func (l *listPolicy[K, V]) Access(h *LRUHandle[K, V]) {
+	if element := l.elements[h]; element != nil && l.touch {
+		l.list.MoveToFront(element)
+	}
+}
+
+func (l *listPolicy[K, V]) Remove(h *LRUHandle[K, V]) {
+	if element := l.elements[h]; element != nil {
+		l.list.Remove(element)
+		delete(l.elements, h)
+	}
+}
+
+func (l *listPolicy[K, V]) Victim() *LRUHandle[K, V] {
+	element := l.list.Back()
+	if l.newest {
+		element = l.list.Front()
+	}
+	if element == nil {
+		return nil
+	}
+	return element.Value.(*LRUHandle[K, V])
+}
+
+// ========================================LFU=====================================
+
+// LFU：淘汰访问次数最少的entry 次数相同时淘汰最久未被访问的entry
+type lfuPolicy[K comparable, V any] struct {
+	heap  lfuHeap[K, V]
+	items map[*LRUHandle[K, V]]*lfuItem[K, V]
+	tick  uint64 // 逻辑时钟：用于比较访问的先后
+}
+
+type lfuItem[K comparable, V any] struct {
+	h     *LRUHandle[K, V]
+	count uint64
+	tick  uint64
+	index int
+}
+
+func NewLFUPolicy[K comparable, V any]() Policy[K, V] {
+	return &lfuPolicy[K, V]{items: make(map[*LRUHandle[K, V]]*lfuItem[K, V])}
+}
+
+func (l *lfuPolicy[K, V]) Insert(h *LRUHandle[K, V]) {
+	l.tick++
+	item := &lfuItem[K, V]{h: h, count: 1, tick: l.tick}
+	l.items[h] = item
+	heap.Push(&l.heap, item)
+}
+
+func (l *lfuPolicy[K, V]) Access(h *LRUHandle[K, V]) {
+	if item := l.items[h]; item != nil {
+		l.tick++
+		item.count++
+		item.tick = l.tick
+		heap.Fix(&l.heap, item.index)
+	}
+}
+
+func (l *lfuPolicy[K, V]) Remove(h *LRUHandle[K, V]) {
+	if item := l.items[h]; item != nil {
+		heap.Remove(&l.heap, item.index)
+		delete(l.items, h)
+	}
+}
+
+func (l *lfuPolicy[K, V]) Victim() *LRUHandle[K, V] {
+	if len(l.heap) == 0 {
+		return nil
+	}
+	return l.heap[0].h
+}
+
+// 最小堆：访问次数最少(次数相同则最久未被访问)的entry位于堆顶
+type lfuHeap[K comparable, V any] []*lfuItem[K, V]
+
+func (q lfuHeap[K, V]) Len() int { return len(q) }
+
+func (q lfuHeap[K, V]) Less(i, j int) bool {
+	if q[i].count != q[j].count {
+		return q[i].count < q[j].count
+	}
+	return q[i].tick < q[j].tick
+}
+
+func (q lfuHeap[K, V]) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *lfuHeap[K, V]) Push(x any) {
+	item := x.(*lfuItem[K, V])
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *lfuHeap[K, V]) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return item
+}
+
+// ========================================Random=====================================
+
+// 随机淘汰
+type randomPolicy[K comparable, V any] struct {
+	handles []*LRUHandle[K, V]
+	index   map[*LRUHandle[K, V]]int
+	rand    *rand.Rand
+}
+
+func NewRandomPolicy[K comparable, V any]() Policy[K, V] {
+	return &randomPolicy[K, V]{
+		index: make(map[*LRUHandle[K, V]]int),
+		rand:  rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+func (r *randomPolicy[K, V]) Insert(h *LRUHandle[K, V]) {
+	r.index[h] = len(r.handles)
+	r.handles = append(r.handles, h)
+}
+
+func (r *randomPolicy[K, V]) Access(h *LRUHandle[K, V]) {}
+
+// 与最后一个元素交换后删除：O(1)
+func (r *randomPolicy[K, V]) Remove(h *LRUHandle[K, V]) {
+	i, ok := r.index[h]
+	if !ok {
+		return
+	}
+	last := len(r.handles) - 1
+	r.handles[i] = r.handles[last]
+	r.index[r.handles[i]] = i
+	r.handles[last] = nil
+	r.handles = r.handles[:last]
+	delete(r.index, h)
+}
+
+func (r *randomPolicy[K, V]) Victim() *LRUHandle[K, V] {
+	if len(r.handles) == 0 {
+		return nil
+	}
+	return r.handles[r.rand.Intn(len(r.handles))]
+}
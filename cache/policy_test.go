@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// 非准入策略：新插入的entry不会被立即淘汰
+func TestPolicyKeepsNewEntry(t *testing.T) {
+	for name, policy := range map[string]func() Policy[int, int]{
+		"LRU":    NewLRUPolicy[int, int],
+		"MRU":    NewMRUPolicy[int, int],
+		"FIFO":   NewFIFOPolicy[int, int],
+		"LFU":    NewLFUPolicy[int, int],
+		"Random": NewRandomPolicy[int, int],
+	} {
+		c, err := NewLRUCache[int, int](10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetPolicy(policy())
+		for k := 0; k < 10; k++ {
+			c.Set(k, k, 1)
+			c.Get(k) // LFU：已有的entry访问次数均大于新entry
+		}
+		for k := 10; k < 20; k++ {
+			c.Set(k, k, 1)
+			if _, ok := c.Entry(k); !ok {
+				t.Errorf("%s: new entry %d was evicted on insert", name, k)
+			}
+			if c.Length() != 10 {
+				t.Errorf("%s: Length = %d, want 10", name, c.Length())
+			}
+		}
+		c.Close()
+	}
+}
+
+// 无效的占比返回ErrInvalidRatio 且不改变当前的策略
+func TestInvalidRatio(t *testing.T) {
+	c, err := NewLRUCache[int, int](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, ratio := range []float64{0, 1, -0.5, 1.5, math.NaN()} {
+		if _, err := NewSLRUPolicy[int, int](ratio); !errors.Is(err, ErrInvalidRatio) {
+			t.Errorf("NewSLRUPolicy(%v) err = %v, want ErrInvalidRatio", ratio, err)
+		}
+		if _, err := NewTinyLFUPolicy[int, int](ratio, 10, nil); !errors.Is(err, ErrInvalidRatio) {
+			t.Errorf("NewTinyLFUPolicy(%v) err = %v, want ErrInvalidRatio", ratio, err)
+		}
+		if err := c.SetSegmented(ratio); !errors.Is(err, ErrInvalidRatio) {
+			t.Errorf("SetSegmented(%v) err = %v, want ErrInvalidRatio", ratio, err)
+		}
+		if err := c.EnableTinyLFU(ratio); !errors.Is(err, ErrInvalidRatio) {
+			t.Errorf("EnableTinyLFU(%v) err = %v, want ErrInvalidRatio", ratio, err)
+		}
+		if c.Policy() != nil {
+			t.Fatalf("policy = %T after invalid ratio %v, want nil", c.Policy(), ratio)
+		}
+	}
+}
+
+// 创建失败时返回的Cache为nil接口 而不是包装了nil指针的接口
+func TestNewInvalidCapacity(t *testing.T) {
+	for _, algorithm := range []Algorithm{AlgorithmLRU, AlgorithmARC, AlgorithmSLRU, AlgorithmTinyLFU} {
+		c, err := New(0, WithAlgorithm(algorithm))
+		if !errors.Is(err, ErrInvalidCapacity) {
+			t.Errorf("New(0, %v) err = %v, want ErrInvalidCapacity", algorithm, err)
+		}
+		if c != nil {
+			t.Errorf("New(0, %v) = %#v, want nil", algorithm, c)
+		}
+	}
+}
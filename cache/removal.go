@@ -0,0 +1,70 @@
+package cache
+
+// entry被移出cache的原因
+type RemovalReason int
+
+const (
+	RemovalEvicted  RemovalReason = iota // cache容量不足被淘汰
+	RemovalReplaced                      // 被相同key的新entry替换
+	RemovalErased                        // 被Erase、RemoveFront、RemoveBack删除
+	RemovalTaken                         // 被Take、PopFront、PopBack取出 handle交由调用方
+	RemovalExpired                       // 已过期 被Lookup或后台清理回收
+	RemovalCleared                       // 被Clear清空
+	RemovalClosed                        // cache被Close
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case RemovalEvicted:
+		return "evicted"
+	case RemovalReplaced:
+		return "replaced"
+	case RemovalErased:
+		return "erased"
+	case RemovalTaken:
+		return "taken"
+	case RemovalExpired:
+		return "expired"
+	case RemovalCleared:
+		return "cleared"
+	case RemovalClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// 是否为cache自身的回收(淘汰、过期) 而非调用方显式的删除
+func (r RemovalReason) Automatic() bool {
+	return r == RemovalEvicted || r == RemovalExpired
+}
+
+// cache级别的移除监听：entry被移出cache时回调 与entry自身的deleter互不影响
+// 注：entry移出cache时即回调(此时其handle可能仍被引用 deleter要等到最后一个handle释放时才调用)
+// 回调时LRUCache持有锁 不能回调LRUCache的方法 耗时的操作应异步处理
+type RemovalListener[K comparable, V any] func(key K, value V, size int64, reason RemovalReason)
+
+// 设置移除监听 nil表示取消
+func (p *LRUCache[K, V]) SetRemovalListener(listener RemovalListener[K, V]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.listener = listener
+}
+
+// 必须持有锁
+func (p *_LRUCache[K, V]) notifyRemoval(h *LRUHandle[K, V], reason RemovalReason) {
+	p.walRemove(h, reason)
+	if (reason == RemovalEvicted || reason == RemovalClosed) && p.spill != nil { // 关闭时仍在cache中的entry同样写入下一级
+		p.spill(h)
+	}
+	if p.listener != nil {
+		p.listener(h.key, h.value, h.size, reason)
+	}
+}
+
+// 为所有分片设置移除监听
+func (p *ShardedLRUCache) SetRemovalListener(listener RemovalListener[string, interface{}]) {
+	for _, s := range p.shards {
+		s.SetRemovalListener(listener)
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type removal struct {
+	key    string
+	value  int
+	size   int64
+	reason RemovalReason
+}
+
+// 每种移除方式回调对应的RemovalReason
+func TestRemovalListenerReasons(t *testing.T) {
+	c, err := NewLRUCache[string, int](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []removal
+	c.SetRemovalListener(func(key string, value int, size int64, reason RemovalReason) {
+		got = append(got, removal{key, value, size, reason})
+	})
+	expect := func(step string, want ...removal) {
+		t.Helper()
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: removals = %v, want %v", step, got, want)
+		}
+		got = nil
+	}
+
+	c.Set("a", 1, 1)
+	c.Set("b", 2, 1)
+	c.Set("c", 3, 1)
+	expect("insert")
+	c.Set("d", 4, 2) // 淘汰最旧的a、b
+	expect("eviction", removal{"a", 1, 1, RemovalEvicted}, removal{"b", 2, 1, RemovalEvicted})
+
+	c.Set("c", 30, 1)
+	expect("replace", removal{"c", 3, 1, RemovalReplaced})
+
+	c.Erase("c")
+	c.Erase("missing")
+	expect("erase", removal{"c", 30, 1, RemovalErased})
+
+	h, _ := c.Take("d")
+	expect("take", removal{"d", 4, 2, RemovalTaken})
+	h.Close()
+
+	if err := c.SetWithOptions("e", 5, 1, nil, WithTTL(time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	c.SetWithOptions("f", 6, 1, nil, WithTTL(time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	c.Get("e") // Lookup回收
+	c.Sweep()  // 后台清理回收
+	expect("expiry", removal{"e", 5, 1, RemovalExpired}, removal{"f", 6, 1, RemovalExpired})
+
+	c.Set("g", 7, 1)
+	c.Clear()
+	expect("clear", removal{"g", 7, 1, RemovalCleared})
+
+	c.Set("h", 8, 1)
+	c.Close()
+	expect("close", removal{"h", 8, 1, RemovalClosed})
+}
+
+// 设置为nil后不再回调 entry自身的deleter不受影响
+func TestRemovalListenerUnset(t *testing.T) {
+	c, err := NewLRUCache[string, int](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	n, deleted := 0, 0
+	c.SetRemovalListener(func(string, int, int64, RemovalReason) { n++ })
+	c.Set("a", 1, 1, func(string, int) { deleted++ })
+	c.Erase("a")
+	c.SetRemovalListener(nil)
+	c.Set("b", 2, 1, func(string, int) { deleted++ })
+	c.Erase("b")
+	if n != 1 || deleted != 2 {
+		t.Errorf("listener called %d times, deleter %d times, want 1, 2", n, deleted)
+	}
+}
+
+func TestRemovalReasonString(t *testing.T) {
+	for reason, want := range map[RemovalReason]string{
+		RemovalEvicted: "evicted", RemovalReplaced: "replaced", RemovalErased: "erased", RemovalTaken: "taken",
+		RemovalExpired: "expired", RemovalCleared: "cleared", RemovalClosed: "closed", RemovalReason(100): "unknown",
+	} {
+		if got := reason.String(); got != want {
+			t.Errorf("RemovalReason(%d).String() = %q, want %q", reason, got, want)
+		}
+	}
+}
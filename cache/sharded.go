@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+var _ NamespacedCache = (*ShardedLRUCache)(nil)
+
+// 默认分片数
+const DefaultShardCount = 16
+
+// 分片LRU cache：根据key的hash将数据分散到多个独立的LRUCache
+// 每个分片各自持有锁，降低并发访问时对单一锁的竞争
+// See https://github.com/google/leveldb/blob/master/util/cache.cc (ShardedLRUCache)
+type ShardedLRUCache struct {
+	shards []*LRUCache[string, interface{}]
+
+	// 所有分片共享同一个id空间
+	last_id uint64
+
+	// 命名空间的generation 见namespace.go
+	namespaces namespaces
+}
+
+// 创建分片LRU cache
+// 总容量capacity均分到shardCount个分片中 余数分给前面的分片：Capacity()恰好为capacity
+// capacity <= 0时返回ErrInvalidCapacity shardCount <= 0时使用DefaultShardCount
+func NewShardedLRUCache(capacity int64, shardCount int) (*ShardedLRUCache, error) {
+	if err := validateCapacity(capacity); err != nil {
+		return nil, err
+	}
+	if shardCount <= 0 {
+		shardCount = DefaultShardCount
+	}
+
+	if int64(shardCount) > capacity { // 保证每个分片的capacity > 0
+		shardCount = int(capacity)
+	}
+
+	p := &ShardedLRUCache{
+		shards: make([]*LRUCache[string, interface{}], shardCount),
+	}
+	for i := range p.shards {
+		p.shards[i], _ = NewLRUCache[string, interface{}](shardSize(capacity, shardCount, i)) // shardCount <= capacity 每个分片 > 0
+	}
+	return p, nil
+}
+
+// 最大分片(第0个)的容量：向上取整
+func shardCapacity(capacity int64, shardCount int) int64 {
+	n := int64(shardCount)
+	return (capacity + n - 1) / n
+}
+
+// 第i个分片的容量：均分后余数分给前面的capacity % shardCount个分片 总和恰好为capacity
+func shardSize(capacity int64, shardCount int, i int) int64 {
+	n := int64(shardCount)
+	size := capacity / n
+	if int64(i) < capacity%n {
+		size++
+	}
+	return size
+}
+
+// 根据key选择分片
+func (p *ShardedLRUCache) shard(key string) *LRUCache[string, interface{}] {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return p.shards[h.Sum32()%uint32(len(p.shards))]
+}
+
+// 分片数
+func (p *ShardedLRUCache) ShardCount() int {
+	return len(p.shards)
+}
+
+//
+func (p *ShardedLRUCache) NewId() uint64 {
+	return atomic.AddUint64(&p.last_id, 1)
+}
+
+// 插入
+func (p *ShardedLRUCache) Insert(key string, value interface{}, size int, deleter func(key string, value interface{})) (handle io.Closer, err error) {
+	return p.shard(key).Insert(key, value, size, deleter)
+}
+
+// 查询
+func (p *ShardedLRUCache) Lookup(key string) (value interface{}, handle io.Closer, ok bool) {
+	return p.shard(key).Lookup(key)
+}
+
+// 删除
+func (p *ShardedLRUCache) Erase(key string) {
+	p.shard(key).Erase(key)
+}
+
+// 查询entry的元数据
+func (p *ShardedLRUCache) Entry(key string) (EntryInfo, bool) {
+	return p.shard(key).Entry(key)
+}
+
+// 关闭所有分片
+func (p *ShardedLRUCache) Close() error {
+	var errs []error
+	for _, s := range p.shards {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 为所有分片开启或关闭泄漏检测
+func (p *ShardedLRUCache) SetLeakDetection(enabled bool) {
+	for _, s := range p.shards {
+		s.SetLeakDetection(enabled)
+	}
+}
+
+// 所有分片中调用方仍持有的handle
+func (p *ShardedLRUCache) OutstandingHandles() (handles []OutstandingHandle) {
+	for _, s := range p.shards {
+		handles = append(handles, s.OutstandingHandles()...)
+	}
+	return
+}
+
+// 查询
+func (p *ShardedLRUCache) Get(key string) (value interface{}, ok bool) {
+	return p.shard(key).Get(key)
+}
+
+// 同LRUCache.GetFrom
+func (p *ShardedLRUCache) GetFrom(key string, getter func(key string) (v interface{}, size int, err error)) (value interface{}, err error) {
+	return p.shard(key).GetFrom(key, getter)
+}
+
+// 同LRUCache.GetFromContext
+func (p *ShardedLRUCache) GetFromContext(ctx context.Context, key string, getter func(ctx context.Context, key string) (v interface{}, size int, err error)) (value interface{}, err error) {
+	return p.shard(key).GetFromContext(ctx, key, getter)
+}
+
+// 设置
+func (p *ShardedLRUCache) Set(key string, value interface{}, size int, deleter ...func(key string, value interface{})) error {
+	return p.shard(key).Set(key, value, size, deleter...)
+}
+
+// 同LRUCache.Value
+func (p *ShardedLRUCache) Value(key string, defaultValue ...interface{}) interface{} {
+	return p.shard(key).Value(key, defaultValue...)
+}
+
+// 设置cache的总capacity 并重新均分到各个分片 Capacity()恰好为capacity
+// 每个分片至少为1：capacity小于分片数时返回ErrInvalidCapacity
+func (p *ShardedLRUCache) SetCapacity(capacity int64) error {
+	if err := validateCapacity(capacity); err != nil {
+		return err
+	}
+	if capacity < int64(len(p.shards)) {
+		return fmt.Errorf("%w: %d is less than shard count %d", ErrInvalidCapacity, capacity, len(p.shards))
+	}
+
+	for i, s := range p.shards {
+		s.SetCapacity(shardSize(capacity, len(p.shards), i))
+	}
+	return nil
+}
+
+// 统计信息：汇总所有分片
+// oldest取所有分片中最早的访问时间
+func (p *ShardedLRUCache) Stats() (length, size, capacity int64, oldest time.Time) {
+	for _, s := range p.shards {
+		l, sz, c, o := s.Stats()
+		length += l
+		size += sz
+		capacity += c
+		if !o.IsZero() && (oldest.IsZero() || o.Before(oldest)) {
+			oldest = o
+		}
+	}
+	return
+}
+
+// 所有分片统计信息的汇总
+func (p *ShardedLRUCache) StatsSnapshot() (s StatsSnapshot) {
+	for _, shard := range p.shards {
+		s.add(shard.StatsSnapshot())
+	}
+	return
+}
+
+// 重置所有分片的计数
+func (p *ShardedLRUCache) ResetStats() {
+	for _, s := range p.shards {
+		s.ResetStats()
+	}
+}
+
+// 所有分片汇总的命中率
+func (p *ShardedLRUCache) HitRatio() float64 {
+	return p.StatsSnapshot().HitRatio()
+}
+
+// 统计信息json格式：所有分片的汇总
+func (p *ShardedLRUCache) StatsJSON() string {
+	if p == nil {
+		return "{}"
+	}
+	s := p.StatsSnapshot()
+	b, err := json.MarshalIndent(struct {
+		Shards int
+		StatsSnapshot
+		HitRatio float64
+	}{len(p.shards), s, s.HitRatio()}, "", "\t")
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// cache中element的个数
+func (p *ShardedLRUCache) Length() (length int64) {
+	for _, s := range p.shards {
+		length += s.Length()
+	}
+	return
+}
+
+//
+func (p *ShardedLRUCache) Size() (size int64) {
+	for _, s := range p.shards {
+		size += s.Size()
+	}
+	return
+}
+
+func (p *ShardedLRUCache) Capacity() (capacity int64) {
+	for _, s := range p.shards {
+		capacity += s.Capacity()
+	}
+	return
+}
+
+// cache中所有的keys
+// 注：仅在单个分片内按照使用时间排序，分片之间依次拼接
+func (p *ShardedLRUCache) Keys() []string {
+	keys := make([]string, 0, p.Length())
+	for _, s := range p.shards {
+		keys = append(keys, s.Keys()...)
+	}
+	return keys
+}
+
+// 清除所有分片
+func (p *ShardedLRUCache) Clear() {
+	for _, s := range p.shards {
+		s.Clear()
+	}
+}
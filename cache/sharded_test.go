@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestShardedCapacity(t *testing.T) {
+	for _, tc := range []struct {
+		capacity int64
+		shards   int
+	}{
+		{100, 16}, {16, 16}, {17, 16}, {1000, 7}, {5, 16},
+	} {
+		c, err := NewShardedLRUCache(tc.capacity, tc.shards)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Capacity(); got != tc.capacity {
+			t.Errorf("NewShardedLRUCache(%d, %d).Capacity() = %d", tc.capacity, tc.shards, got)
+		}
+		for _, s := range c.shards {
+			if s.Capacity() <= 0 {
+				t.Errorf("NewShardedLRUCache(%d, %d): shard capacity %d", tc.capacity, tc.shards, s.Capacity())
+			}
+		}
+
+		for _, capacity := range []int64{tc.capacity * 3, tc.capacity + 1, int64(c.ShardCount())} {
+			if err := c.SetCapacity(capacity); err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Capacity(); got != capacity {
+				t.Errorf("SetCapacity(%d) on %d shards: Capacity() = %d", capacity, c.ShardCount(), got)
+			}
+		}
+		if err := c.SetCapacity(int64(c.ShardCount() - 1)); !errors.Is(err, ErrInvalidCapacity) {
+			t.Errorf("SetCapacity(%d) on %d shards: err = %v, want ErrInvalidCapacity", c.ShardCount()-1, c.ShardCount(), err)
+		}
+		c.Close()
+	}
+}
+
+func TestShardedDistribution(t *testing.T) {
+	c, err := NewShardedLRUCache(1<<20, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	const n = 1600
+	for i := 0; i < n; i++ {
+		if err := c.Set(strconv.Itoa(i), i, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := c.Length(); got != n {
+		t.Fatalf("Length() = %d, want %d", got, n)
+	}
+	avg := int64(n / c.ShardCount())
+	for i, s := range c.shards {
+		if l := s.Length(); l < avg/2 || l > avg*2 {
+			t.Errorf("shard %d holds %d keys, average %d", i, l, avg)
+		}
+	}
+	for i := 0; i < n; i++ { // 同一key总是落在同一分片
+		key := strconv.Itoa(i)
+		if v, ok := c.shard(key).Get(key); !ok || v != i {
+			t.Fatalf("shard(%q).Get = %v, %v", key, v, ok)
+		}
+	}
+}
+
+func TestShardedStatsAggregate(t *testing.T) {
+	c, err := NewShardedLRUCache(64, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 100; i++ { // 每个分片容量16：部分被淘汰
+		c.Set(strconv.Itoa(i), i, 1)
+	}
+	for i := 0; i < 100; i++ {
+		c.Get(strconv.Itoa(i))
+	}
+	c.Set("99", 99, 1) // 替换
+	c.Erase("98")
+
+	var want StatsSnapshot
+	var length, size, capacity int64
+	for _, s := range c.shards {
+		want.add(s.StatsSnapshot())
+		l, sz, cp, _ := s.Stats()
+		length += l
+		size += sz
+		capacity += cp
+	}
+	got := c.StatsSnapshot()
+	if got != want {
+		t.Errorf("StatsSnapshot() = %+v\nwant sum of shards %+v", got, want)
+	}
+	if got.Hits+got.Misses != 100 || got.Hits == 0 || got.Misses == 0 || got.Evictions == 0 || got.Replacements == 0 || got.Erases == 0 {
+		t.Errorf("StatsSnapshot() = %+v", got)
+	}
+	if l, sz, cp, _ := c.Stats(); l != length || sz != size || cp != capacity || cp != 64 {
+		t.Errorf("Stats() = %d, %d, %d, want %d, %d, %d", l, sz, cp, length, size, capacity)
+	}
+
+	c.ResetStats()
+	if s := c.StatsSnapshot(); s.Hits != 0 || s.Misses != 0 || s.Evictions != 0 {
+		t.Errorf("after ResetStats: %+v", s)
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// 正在执行中的getter调用
+type call[V any] struct {
+	done chan struct{} // getter结束后关闭
+
+	val  V
+	size int
+	err  error
+
+	dups    int64 // 被合并(等待共享结果)的调用次数
+	waiters int   // 仍在等待结果的调用方数
+
+	ctx        context.Context // getter使用的context：所有等待者都离开后被取消
+	cancel     context.CancelFunc
+	completing bool // getter已返回 正在set到cache：此时不再放弃
+}
+
+// 合并同一key的并发getter调用：只有第一个调用方(leader)发起getter
+// 其余调用方等待并共享getter的结果
+// See https://github.com/golang/groupcache/blob/master/singleflight/singleflight.go
+type flightGroup[K comparable, V any] struct {
+	mu sync.Mutex
+	m  map[K]*call[V] // 延迟初始化
+}
+
+// 加入key对应的调用
+// 若是没有正在执行的调用 则创建一个新的调用并返回leader = true
+// 新调用的context继承ctx中的value 但不继承其取消：只有所有等待者都离开才会取消
+func (g *flightGroup[K, V]) join(ctx context.Context, key K) (c *call[V], leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.m == nil {
+		g.m = make(map[K]*call[V])
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.waiters++
+		return c, false
+	}
+
+	c = &call[V]{done: make(chan struct{}), waiters: 1}
+	c.ctx, c.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	g.m[key] = c
+	return c, true
+}
+
+// 等待者放弃等待(取消或超时)
+// 最后一个等待者离开时放弃整个调用：取消getter的context 且结果不会被set到cache
+func (g *flightGroup[K, V]) leave(key K, c *call[V]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c.waiters--
+	if c.waiters > 0 || c.completing {
+		return
+	}
+	if g.m[key] == c {
+		delete(g.m, key)
+	}
+	c.cancel()
+}
+
+// getter返回后调用：判断调用是否已被放弃
+// 若未被放弃 则标记为completing，之后等待者的离开不再放弃该调用
+func (g *flightGroup[K, V]) complete(key K, c *call[V]) (abandoned bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.m[key] != c {
+		return true
+	}
+	c.completing = true
+	return false
+}
+
+// 结束调用：移除key对应的调用并唤醒所有等待者
+// 返回被合并的调用次数
+func (g *flightGroup[K, V]) finish(key K, c *call[V]) (dups int64) {
+	g.mu.Lock()
+	if g.m[key] == c {
+		delete(g.m, key)
+	}
+	dups = c.dups
+	g.mu.Unlock()
+
+	close(c.done)
+	c.cancel()
+	return dups
+}
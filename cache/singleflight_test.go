@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 等待key对应的加载有n个等待者
+func waitWaiters[K comparable, V any](t *testing.T, g *flightGroup[K, V], key K, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		g.mu.Lock()
+		c, ok := g.m[key]
+		got := 0
+		if ok {
+			got = c.waiters
+		}
+		g.mu.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("waiters of %v = %d, want %d", key, got, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGetFromCollapsesConcurrentLoads(t *testing.T) {
+	c, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	const n = 32
+	var calls atomic.Int32
+	release := make(chan struct{})
+	getter := func(key string) (int, int, error) {
+		calls.Add(1)
+		<-release
+		return 42, 1, nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetFrom("k", getter)
+			if err == nil && v != 42 {
+				err = errors.New("wrong value")
+			}
+			errs <- err
+		}()
+	}
+	waitWaiters(t, &c.flight, "k", n)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("getter called %d times, want 1", got)
+	}
+	if merged, ok := c.MergedCalls("k"); !ok || merged != n-1 {
+		t.Errorf("MergedCalls = %d, %v, want %d, true", merged, ok, n-1)
+	}
+}
+
+func TestGetFromErrorNotCached(t *testing.T) {
+	c, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	errBackend := errors.New("backend down")
+	var calls atomic.Int32
+	release := make(chan struct{})
+	getter := func(key string) (int, int, error) {
+		if calls.Add(1) == 1 {
+			<-release
+			return 0, 0, errBackend
+		}
+		return 7, 1, nil
+	}
+
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetFrom("k", getter)
+			errs <- err
+		}()
+	}
+	waitWaiters(t, &c.flight, "k", n)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs { // 所有等待者共享同一个错误
+		if !errors.Is(err, errBackend) {
+			t.Fatalf("err = %v, want %v", err, errBackend)
+		}
+	}
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("failed load was cached")
+	}
+	if v, err := c.GetFrom("k", getter); err != nil || v != 7 {
+		t.Fatalf("GetFrom after error = %d, %v, want 7, nil", v, err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("getter called %d times, want 2", got)
+	}
+}
+
+func TestGetFromContextCancelDoesNotAffectOtherWaiters(t *testing.T) {
+	c, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	release := make(chan struct{})
+	var getterErr error
+	getter := func(ctx context.Context, key string) (int, int, error) {
+		<-release
+		getterErr = ctx.Err()
+		return 42, 1, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := c.GetFromContext(ctx, "k", getter)
+		canceled <- err
+	}()
+	waitWaiters(t, &c.flight, "k", 1)
+	other := make(chan error, 1)
+	go func() {
+		v, err := c.GetFromContext(context.Background(), "k", getter)
+		if err == nil && v != 42 {
+			err = errors.New("wrong value")
+		}
+		other <- err
+	}()
+	waitWaiters(t, &c.flight, "k", 2)
+
+	cancel()
+	err = <-canceled
+	var lce *LoadCanceledError
+	if !errors.As(err, &lce) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled caller err = %v, want *LoadCanceledError wrapping context.Canceled", err)
+	}
+	waitWaiters(t, &c.flight, "k", 1)
+
+	close(release)
+	if err := <-other; err != nil {
+		t.Fatalf("other waiter err = %v", err)
+	}
+	if getterErr != nil {
+		t.Fatalf("getter ctx canceled while a waiter remained: %v", getterErr)
+	}
+	if v, ok := c.Get("k"); !ok || v != 42 {
+		t.Errorf("Get = %d, %v, want 42, true", v, ok)
+	}
+}
+
+func TestGetFromContextAbandonedWhenAllWaitersLeave(t *testing.T) {
+	c, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	finished := make(chan struct{})
+	getter := func(ctx context.Context, key string) (int, int, error) {
+		defer close(finished)
+		<-ctx.Done() // 所有调用方离开后被取消
+		return 42, 1, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetFromContext(ctx, "k", getter); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	<-finished
+	time.Sleep(10 * time.Millisecond) // load在getter返回后结束
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("result of abandoned load was cached")
+	}
+}
+
+// getter为nil且key不在cache中：返回ErrNotFound
+func TestGetFromNilGetter(t *testing.T) {
+	c, err := NewLRUCache[string, int](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.GetFrom("missing", nil); !errors.Is(err, ErrNotFound) || err.Error() != "cache: not found: missing" {
+		t.Errorf("GetFrom(missing, nil) err = %v, want ErrNotFound", err)
+	}
+	c.Set("a", 1, 1)
+	if v, err := c.GetFrom("a", nil); err != nil || v != 1 {
+		t.Errorf("GetFrom(a, nil) = %v, %v", v, err)
+	}
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// 插入时size为0表示自动计算entry的size：
+// 默认(SizeOf)：value实现了Sizer时使用其Size() 否则通过反射估算value占用的内存(EstimateSize)
+// 可通过SetSizeFunc设置整个cache的计算方式 如UnitSize(按entry个数计算容量)
+// 注：size为负数时仍返回ErrInvalidSize
+
+// value自身报告其size
+type Sizer interface {
+	Size() int
+}
+
+// 计算value的size 返回值 <= 0 时插入返回ErrInvalidSize
+type SizeFunc[V any] func(value V) int
+
+// 默认的计算方式：Sizer优先 否则估算 结果至少为1
+func SizeOf(value interface{}) int {
+	if s, ok := value.(Sizer); ok {
+		return s.Size()
+	}
+	return max(EstimateSize(value), 1)
+}
+
+// 每个entry的size均为1：capacity即为entry的最大个数
+func UnitSize[V any](V) int {
+	return 1
+}
+
+// 设置size为0时的计算方式 nil表示使用SizeOf
+func (p *LRUCache[K, V]) SetSizeFunc(fn SizeFunc[V]) {
+	if fn == nil {
+		p.sizeFunc.Store(nil)
+	} else {
+		p.sizeFunc.Store(&fn)
+	}
+}
+
+// 设置size为0时的计算方式 nil表示使用SizeOf
+func (p *ARCCache[K, V]) SetSizeFunc(fn SizeFunc[V]) {
+	if fn == nil {
+		p.sizeFunc.Store(nil)
+	} else {
+		p.sizeFunc.Store(&fn)
+	}
+}
+
+// 为所有分片设置size的计算方式
+func (p *ShardedLRUCache) SetSizeFunc(fn SizeFunc[interface{}]) {
+	for _, s := range p.shards {
+		s.SetSizeFunc(fn)
+	}
+}
+
+// size为0时自动计算 不需要持有锁
+func resolveSize[V any](fn *atomic.Pointer[SizeFunc[V]], value V, size int) int {
+	if size != 0 {
+		return size
+	}
+	if f := fn.Load(); f != nil {
+		return (*f)(value)
+	}
+	return SizeOf(value)
+}
+
+// ========================================EstimateSize=====================================
+
+// 估算value占用的内存(字节)：value本身加上其引用的string、slice、map、指针等的内存
+// 同一块内存(如多次引用的指针、slice)只计算一次；不计算chan、func引用的内存
+// 注：只是近似值 如map的内部结构按照每个entry的key和value计算 未计算内存分配器的对齐
+func EstimateSize(value interface{}) int {
+	if value == nil {
+		return 0
+	}
+	v := reflect.ValueOf(value)
+	e := sizeEstimator{seen: make(map[uintptr]bool)}
+	return int(v.Type().Size()) + e.indirect(v)
+}
+
+// map的固定开销以及每个entry的额外开销(控制字节、负载因子)的近似值
+const (
+	mapHeaderSize    = 48
+	mapEntryOverhead = 2
+)
+
+type sizeEstimator struct {
+	seen map[uintptr]bool // 已计算过的内存
+}
+
+// 第一次访问ptr时返回true
+func (e *sizeEstimator) visit(ptr uintptr) bool {
+	if e.seen[ptr] {
+		return false
+	}
+	e.seen[ptr] = true
+	return true
+}
+
+// v引用的内存 不包括v本身
+func (e *sizeEstimator) indirect(v reflect.Value) (n int) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.Len()
+
+	case reflect.Pointer:
+		if v.IsNil() || !e.visit(v.Pointer()) {
+			return 0
+		}
+		elem := v.Elem()
+		return int(elem.Type().Size()) + e.indirect(elem)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		elem := v.Elem()
+		if elem.Kind() != reflect.Pointer && elem.Kind() != reflect.Map { // 非指针的值在interface中单独分配
+			n = int(elem.Type().Size())
+		}
+		return n + e.indirect(elem)
+
+	case reflect.Slice:
+		if v.IsNil() || !e.visit(v.Pointer()) {
+			return 0
+		}
+		n = v.Cap() * int(v.Type().Elem().Size())
+		if references(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				n += e.indirect(v.Index(i))
+			}
+		}
+		return n
+
+	case reflect.Array:
+		if references(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				n += e.indirect(v.Index(i))
+			}
+		}
+		return n
+
+	case reflect.Map:
+		if v.IsNil() || !e.visit(v.Pointer()) {
+			return 0
+		}
+		t := v.Type()
+		n = mapHeaderSize + v.Len()*(int(t.Key().Size())+int(t.Elem().Size())+mapEntryOverhead)
+		if references(t.Key()) || references(t.Elem()) {
+			iter := v.MapRange()
+			for iter.Next() {
+				n += e.indirect(iter.Key()) + e.indirect(iter.Value())
+			}
+		}
+		return n
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			n += e.indirect(v.Field(i))
+		}
+		return n
+	}
+	return 0
+}
+
+// 类型t的值是否可能引用其他内存(需要遍历)
+func references(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	case reflect.Array:
+		return t.Len() > 0 && references(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if references(t.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
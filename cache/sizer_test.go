@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+type fixedSizer struct{ n int }
+
+func (s fixedSizer) Size() int { return s.n }
+
+func TestEstimateSize(t *testing.T) {
+	const (
+		word      = int(unsafe.Sizeof(uintptr(0)))
+		strHeader = int(unsafe.Sizeof(""))
+		slcHeader = int(unsafe.Sizeof([]byte(nil)))
+	)
+	type pair struct {
+		a, b *[4]int64
+	}
+	shared := new([4]int64)
+
+	for _, tc := range []struct {
+		name  string
+		value interface{}
+		want  int
+	}{
+		{"nil", nil, 0},
+		{"int64", int64(1), 8},
+		{"string", "hello", strHeader + 5},
+		{"byte slice", make([]byte, 3, 10), slcHeader + 10},
+		{"string slice", []string{"ab", "c"}, slcHeader + 2*strHeader + 3},
+		{"nil slice", []int(nil), slcHeader},
+		{"pointer", &[4]int64{}, word + 32},
+		{"shared pointer counted once", pair{shared, shared}, 2*word + 32},
+		{"distinct pointers", pair{new([4]int64), new([4]int64)}, 2*word + 64},
+		{"map", map[int64]int64{1: 1, 2: 2}, word + mapHeaderSize + 2*(16+mapEntryOverhead)},
+		{"interface slice", []interface{}{int64(1), "x"}, slcHeader + 2*2*word + 8 + strHeader + 1},
+	} {
+		if got := EstimateSize(tc.value); got != tc.want {
+			t.Errorf("EstimateSize(%s) = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSizeOf(t *testing.T) {
+	if got := SizeOf(fixedSizer{42}); got != 42 {
+		t.Errorf("SizeOf(Sizer) = %d, want 42", got)
+	}
+	if got := SizeOf("abc"); got != EstimateSize("abc") {
+		t.Errorf("SizeOf(string) = %d, want EstimateSize %d", got, EstimateSize("abc"))
+	}
+	if got := SizeOf(struct{}{}); got != 1 { // 估算为0时至少为1
+		t.Errorf("SizeOf(struct{}{}) = %d, want 1", got)
+	}
+}
+
+func TestSizeFunc(t *testing.T) {
+	c, err := NewLRUCache[string, string](1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Set("a", "abc", 0); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := c.Entry("a"); e.Size != int64(SizeOf("abc")) {
+		t.Errorf("default size = %d, want SizeOf %d", e.Size, SizeOf("abc"))
+	}
+
+	c.SetSizeFunc(UnitSize[string])
+	if err := c.Set("b", "abcdef", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("c", "abcdef", 5); err != nil { // 显式的size不经过SizeFunc
+		t.Fatal(err)
+	}
+	for key, want := range map[string]int64{"b": 1, "c": 5} {
+		if e, _ := c.Entry(key); e.Size != want {
+			t.Errorf("Entry(%q).Size = %d, want %d", key, e.Size, want)
+		}
+	}
+
+	c.SetSizeFunc(func(string) int { return 0 })
+	if err := c.Set("d", "x", 0); !errors.Is(err, ErrInvalidSize) {
+		t.Errorf("Set with zero SizeFunc err = %v, want ErrInvalidSize", err)
+	}
+	if err := c.Set("d", "x", -1); !errors.Is(err, ErrInvalidSize) {
+		t.Errorf("Set with negative size err = %v, want ErrInvalidSize", err)
+	}
+
+	c.SetSizeFunc(nil)
+	if err := c.Set("e", "abc", 0); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := c.Entry("e"); e.Size != int64(SizeOf("abc")) {
+		t.Errorf("size after SetSizeFunc(nil) = %d, want SizeOf %d", e.Size, SizeOf("abc"))
+	}
+}
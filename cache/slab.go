@@ -0,0 +1,387 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// 独立的[]byte cache：适用于大量较小的[]byte value
+// LRUCache中每个entry都是一个*LRUHandle和一个list.Element entry很多时GC的扫描开销很大
+// SlabCache不为entry创建对象：key和value被复制到每个分片预先分配的环形缓冲区(ring)中
+// 注：SlabCache不是LRUCache的存储模式 也不实现Cache接口：没有handle、deleter、淘汰策略及过期时间
+// 因此只提供Set/Get/Erase等按值复制的方法
+// 索引为map[uint64]uint32(key的hash -> entry在ring中的偏移) 索引和ring均不包含指针 GC无需扫描
+// See https://github.com/allegro/bigcache  https://github.com/coocood/freecache
+//
+// 淘汰：ring空间不足时从最旧的entry开始回收
+// 被访问过的entry有一次"第二次机会"：回收时被重新写入ring的尾部而不是淘汰(近似LRU 类似CLOCK)
+// 被删除或替换的entry不再有效 其占用的空间同样在回收时释放
+//
+// 容量：capacity为所有ring的总字节数 entry的size为其在ring中占用的字节数(16字节的entry头部 + key + value)
+// 与LRUCache不同 size由cache计算而不是由调用方传入 Size()为仍有效的entry的size之和 始终 <= Capacity()
+// 注：Get返回value的副本
+// 索引只保存hash：Get、Erase会比较完整的key hash冲突时视为未命中
+// Set的key与已有entry的hash冲突时 与bigcache、freecache相同 淘汰已有的entry并写入新的entry 见Collisions
+type SlabCache struct {
+	shards   []*slabShard
+	capacity int64
+	hash     func(key string) uint64 // 默认为slabHash
+}
+
+// entry头部：hash(8) | key长度(2) | value长度(4) | flags(1) | 保留(1)
+const (
+	slabHeaderSize = 16
+	slabFlagsPos   = 14
+
+	slabAccessed = 1 // 自上次回收检查后被访问过
+)
+
+type slabShard struct {
+	mu sync.Mutex
+
+	buf []byte
+	// 逻辑位置 单调递增：buf中的偏移为 pos % len(buf)
+	// [head, tail)为仍在ring中的entry(包括已失效的entry)
+	head, tail uint64
+
+	index map[uint64]uint32 // key的hash -> entry在buf中的偏移 只包含有效的entry
+	size  int64             // 有效entry的size之和
+
+	scratch []byte // 第二次机会时移动entry使用
+
+	hits, misses, inserts, replacements, evictions, erases int64
+	collisions                                             int64 // 因hash冲突被覆盖的entry
+
+	closed bool
+}
+
+// 创建SlabCache 所有ring共capacity字节 在创建时分配
+// capacity <= 0时返回ErrInvalidCapacity shardCount <= 0时使用DefaultShardCount
+func NewSlabCache(capacity int64, shardCount int) (*SlabCache, error) {
+	if err := validateCapacity(capacity); err != nil {
+		return nil, err
+	}
+	if shardCount <= 0 {
+		shardCount = DefaultShardCount
+	}
+	if int64(shardCount) > capacity {
+		shardCount = int(capacity)
+	}
+	if largest := shardCapacity(capacity, shardCount); largest > math.MaxUint32 { // 偏移为uint32
+		return nil, fmt.Errorf("%w: %d bytes per shard exceeds %d, use more shards", ErrInvalidCapacity, largest, uint32(math.MaxUint32))
+	}
+
+	p := &SlabCache{
+		shards:   make([]*slabShard, shardCount),
+		capacity: capacity,
+		hash:     slabHash,
+	}
+	for i := range p.shards {
+		p.shards[i] = &slabShard{
+			buf:   make([]byte, shardSize(capacity, shardCount, i)), // 总和恰好为capacity
+			index: make(map[uint64]uint32),
+		}
+	}
+	return p, nil
+}
+
+// FNV-1a 64
+func slabHash(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+func (p *SlabCache) shard(hash uint64) *slabShard {
+	return p.shards[hash%uint64(len(p.shards))]
+}
+
+// 设置 value被复制到ring中
+// key为空返回ErrEmptyKey key超过65535字节或entry超过分片的ring返回ErrEntryTooLarge cache已关闭返回ErrCacheClosed
+// 与其他key的hash冲突时覆盖其entry
+func (p *SlabCache) Set(key string, value []byte) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if len(key) > math.MaxUint16 {
+		return fmt.Errorf("%w: key of %d bytes", ErrEntryTooLarge, len(key))
+	}
+	hash := p.hash(key)
+	s := p.shard(hash)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrCacheClosed
+	}
+	n := slabHeaderSize + len(key) + len(value)
+	if n > len(s.buf) {
+		return fmt.Errorf("%w: size %d exceeds shard capacity %d", ErrEntryTooLarge, n, len(s.buf))
+	}
+
+	if off, ok := s.index[hash]; ok {
+		if s.hasKey(off, key) {
+			s.replacements++
+		} else { // hash冲突：覆盖其他key的entry
+			s.collisions++
+		}
+		s.remove(hash, off)
+	}
+	s.reserve(n)
+
+	var header [slabHeaderSize]byte
+	binary.LittleEndian.PutUint64(header[0:], hash)
+	binary.LittleEndian.PutUint16(header[8:], uint16(len(key)))
+	binary.LittleEndian.PutUint32(header[10:], uint32(len(value)))
+	pos := s.tail
+	s.write(pos, header[:])
+	s.writeString(pos+slabHeaderSize, key)
+	s.write(pos+slabHeaderSize+uint64(len(key)), value)
+
+	s.index[hash] = s.offset(pos)
+	s.tail += uint64(n)
+	s.size += int64(n)
+	s.inserts++
+	return nil
+}
+
+// 查询 返回value的副本
+func (p *SlabCache) Get(key string) (value []byte, ok bool) {
+	hash := p.hash(key)
+	s := p.shard(hash)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	off, ok := s.index[hash]
+	if !ok {
+		s.misses++
+		return nil, false
+	}
+	if !s.hasKey(off, key) { // hash冲突
+		s.misses++
+		return nil, false
+	}
+	_, keyLen, valueLen, _ := s.header(uint64(off))
+
+	value = make([]byte, valueLen)
+	s.read(uint64(off)+slabHeaderSize+uint64(keyLen), value)
+	s.buf[s.offset(uint64(off)+slabFlagsPos)] |= slabAccessed
+	s.hits++
+	return value, true
+}
+
+// 删除 entry占用的空间在回收时释放
+func (p *SlabCache) Erase(key string) {
+	hash := p.hash(key)
+	s := p.shard(hash)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	off, ok := s.index[hash]
+	if !ok {
+		return
+	}
+	if !s.hasKey(off, key) {
+		return
+	}
+	s.remove(hash, off)
+	s.erases++
+}
+
+// 清除所有分片
+func (p *SlabCache) Clear() {
+	for _, s := range p.shards {
+		s.mu.Lock()
+		s.head, s.tail = 0, 0
+		s.index = make(map[uint64]uint32)
+		s.size = 0
+		s.mu.Unlock()
+	}
+}
+
+// 关闭cache：释放所有ring 关闭后查询均未命中 Set返回ErrCacheClosed
+func (p *SlabCache) Close() error {
+	for _, s := range p.shards {
+		s.mu.Lock()
+		s.closed = true
+		s.buf = nil
+		s.scratch = nil
+		s.head, s.tail = 0, 0
+		s.index = make(map[uint64]uint32)
+		s.size = 0
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// cache中entry的个数
+func (p *SlabCache) Length() (length int64) {
+	for _, s := range p.shards {
+		s.mu.Lock()
+		length += int64(len(s.index))
+		s.mu.Unlock()
+	}
+	return
+}
+
+// 有效entry占用的字节数
+func (p *SlabCache) Size() (size int64) {
+	for _, s := range p.shards {
+		s.mu.Lock()
+		size += s.size
+		s.mu.Unlock()
+	}
+	return
+}
+
+func (p *SlabCache) Capacity() int64 {
+	return p.capacity
+}
+
+// 分片数
+func (p *SlabCache) ShardCount() int {
+	return len(p.shards)
+}
+
+// 统计信息快照：所有分片的汇总
+// 注：不包含OldestAccess及加载相关的计数 Evictions不包括第二次机会时被移动的entry
+func (p *SlabCache) StatsSnapshot() (s StatsSnapshot) {
+	s.Capacity = p.capacity
+	for _, shard := range p.shards {
+		shard.mu.Lock()
+		s.Length += int64(len(shard.index))
+		s.Size += shard.size
+		s.Hits += shard.hits
+		s.Misses += shard.misses
+		s.Inserts += shard.inserts
+		s.Replacements += shard.replacements
+		s.Evictions += shard.evictions
+		s.Erases += shard.erases
+		shard.mu.Unlock()
+	}
+	return s
+}
+
+// 因与其他key的hash冲突而被Set覆盖的entry个数
+func (p *SlabCache) Collisions() (n int64) {
+	for _, s := range p.shards {
+		s.mu.Lock()
+		n += s.collisions
+		s.mu.Unlock()
+	}
+	return
+}
+
+// 重置所有计数
+func (p *SlabCache) ResetStats() {
+	for _, s := range p.shards {
+		s.mu.Lock()
+		s.hits, s.misses, s.inserts, s.replacements, s.evictions, s.erases = 0, 0, 0, 0, 0, 0
+		s.collisions = 0
+		s.mu.Unlock()
+	}
+}
+
+// 命中率
+func (p *SlabCache) HitRatio() float64 {
+	return p.StatsSnapshot().HitRatio()
+}
+
+// ========================================slabShard=====================================
+// 以下方法必须持有锁
+
+// 逻辑位置在buf中的偏移
+func (s *slabShard) offset(pos uint64) uint32 {
+	return uint32(pos % uint64(len(s.buf)))
+}
+
+// 从pos读取len(dst)字节 超过buf末尾时从头部继续
+func (s *slabShard) read(pos uint64, dst []byte) {
+	n := copy(dst, s.buf[s.offset(pos):])
+	copy(dst[n:], s.buf)
+}
+
+func (s *slabShard) write(pos uint64, src []byte) {
+	n := copy(s.buf[s.offset(pos):], src)
+	copy(s.buf, src[n:])
+}
+
+func (s *slabShard) writeString(pos uint64, src string) {
+	n := copy(s.buf[s.offset(pos):], src)
+	copy(s.buf, src[n:])
+}
+
+// pos处的key是否为key
+func (s *slabShard) equal(pos uint64, key string) bool {
+	off := int(s.offset(pos))
+	if n := len(s.buf) - off; n < len(key) {
+		return string(s.buf[off:]) == key[:n] && string(s.buf[:len(key)-n]) == key[n:]
+	}
+	return string(s.buf[off:off+len(key)]) == key
+}
+
+// off处的entry的key是否为key
+func (s *slabShard) hasKey(off uint32, key string) bool {
+	_, keyLen, _, _ := s.header(uint64(off))
+	return keyLen == len(key) && s.equal(uint64(off)+slabHeaderSize, key)
+}
+
+func (s *slabShard) header(pos uint64) (hash uint64, keyLen, valueLen int, flags byte) {
+	var header [slabHeaderSize]byte
+	s.read(pos, header[:])
+	return binary.LittleEndian.Uint64(header[0:]), int(binary.LittleEndian.Uint16(header[8:])),
+		int(binary.LittleEndian.Uint32(header[10:])), header[slabFlagsPos]
+}
+
+// 使off处的entry失效
+func (s *slabShard) remove(hash uint64, off uint32) {
+	_, keyLen, valueLen, _ := s.header(uint64(off))
+	s.size -= int64(slabHeaderSize + keyLen + valueLen)
+	delete(s.index, hash)
+}
+
+// 回收最旧的entry 直至ring的剩余空间 >= n
+func (s *slabShard) reserve(n int) {
+	for uint64(len(s.buf))-(s.tail-s.head) < uint64(n) {
+		s.evictHead()
+	}
+}
+
+// 回收head处的entry：已失效的entry直接释放；被访问过的有效entry重新写入尾部 否则淘汰
+func (s *slabShard) evictHead() {
+	hash, keyLen, valueLen, flags := s.header(s.head)
+	n := slabHeaderSize + keyLen + valueLen
+
+	off, ok := s.index[hash]
+	if !ok || off != s.offset(s.head) { // 已失效
+		s.head += uint64(n)
+		return
+	}
+
+	if flags&slabAccessed != 0 { // 第二次机会：移动到尾部 先复制出来 源和目标可能重叠
+		if cap(s.scratch) < n {
+			s.scratch = make([]byte, n)
+		}
+		entry := s.scratch[:n]
+		s.read(s.head, entry)
+		entry[slabFlagsPos] &^= slabAccessed
+		s.head += uint64(n)
+		s.write(s.tail, entry)
+		s.index[hash] = s.offset(s.tail)
+		s.tail += uint64(n)
+		return
+	}
+
+	delete(s.index, hash)
+	s.size -= int64(n)
+	s.evictions++
+	s.head += uint64(n)
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+func TestSlabCache(t *testing.T) {
+	c, err := NewSlabCache(1<<12, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Set("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("a", []byte("22")); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := c.Get("a"); !ok || string(v) != "22" {
+		t.Fatalf("Get(a) = %q, %v, want 22, true", v, ok)
+	}
+	if got, want := c.Size(), int64(slabHeaderSize+1+2); got != want {
+		t.Errorf("Size = %d, want %d", got, want)
+	}
+	c.Erase("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get after Erase hit")
+	}
+
+	value := bytes.Repeat([]byte("x"), 100)
+	for i := 0; i < 200; i++ { // 超出capacity：旧的entry被淘汰
+		if err := c.Set(strconv.Itoa(i), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Size() > c.Capacity() {
+		t.Errorf("Size %d exceeds Capacity %d", c.Size(), c.Capacity())
+	}
+	if v, ok := c.Get("199"); !ok || !bytes.Equal(v, value) {
+		t.Error("latest entry missing")
+	}
+	if c.StatsSnapshot().Evictions == 0 {
+		t.Error("no evictions")
+	}
+}
+
+// hash冲突：Get/Erase比较完整的key Set覆盖其他key的entry
+func TestSlabCacheHashCollision(t *testing.T) {
+	c, err := NewSlabCache(1<<12, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.hash = func(string) uint64 { return 42 } // 所有key冲突
+
+	if err := c.Set("a", []byte("A")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("Get(b) hit the entry of a")
+	}
+	if err := c.Set("b", []byte("B")); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := c.Get("b"); !ok || string(v) != "B" {
+		t.Fatalf("Get(b) after colliding Set = %q, %v, want B, true", v, ok)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("overwritten entry of a still hit")
+	}
+	c.Erase("a")
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("Erase(a) removed b")
+	}
+	if got := c.Collisions(); got != 1 {
+		t.Errorf("Collisions = %d, want 1", got)
+	}
+	if s := c.StatsSnapshot(); s.Length != 1 || s.Size != slabHeaderSize+2 || s.Replacements != 0 {
+		t.Errorf("Length = %d, Size = %d, Replacements = %d, want 1, %d, 0", s.Length, s.Size, s.Replacements, slabHeaderSize+2)
+	}
+
+	if err := c.Set("b", []byte("BB")); err != nil { // 相同的key替换
+		t.Fatal(err)
+	}
+	if v, ok := c.Get("b"); !ok || string(v) != "BB" {
+		t.Fatalf("Get(b) after replace = %q, %v, want BB, true", v, ok)
+	}
+	if s := c.StatsSnapshot(); s.Replacements != 1 || c.Collisions() != 1 {
+		t.Errorf("Replacements = %d, Collisions = %d, want 1, 1", s.Replacements, c.Collisions())
+	}
+}
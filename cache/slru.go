@@ -0,0 +1,170 @@
+package cache
+
+import "container/list"
+
+// 默认protected段占比
+const DefaultProtectedRatio = 0.8
+
+// 分段LRU(SLRU)：cache分为probation和protected两段
+// 新插入的entry进入probation段 在probation段中再次被Lookup才晋升到protected段
+// protected段超出其容量(capacity * protectedRatio)时 将其最旧的entry降级回probation段表头
+// 淘汰时优先淘汰probation段中最旧的entry：一次性的全表扫描只会冲刷probation段 不影响protected段中的热点数据
+// See http://highscalability.com/blog/2016/1/25/design-of-a-modern-cache.html
+type SLRUPolicy[K comparable, V any] struct {
+	protectedRatio float64 // protected段容量 = capacity * protectedRatio
+	protectedLimit int64   // 由SetCapacity设置
+
+	probation     *list.List // 存放*slruEntry 表头为最近访问
+	protected     *list.List
+	probationSize int64
+	protectedSize int64
+
+	elements map[*LRUHandle[K, V]]*list.Element
+}
+
+// 创建SLRU策略
+// protectedRatio为protected段占cache的capacity的比例 取值(0, 1) 否则返回ErrInvalidRatio
+// capacity由cache在设置策略时通过SetCapacity传入 见CapacityPolicy
+func NewSLRUPolicy[K comparable, V any](protectedRatio float64) (*SLRUPolicy[K, V], error) {
+	if err := validateRatio("protected", protectedRatio); err != nil {
+		return nil, err
+	}
+
+	return &SLRUPolicy[K, V]{
+		protectedRatio: protectedRatio,
+		probation:      list.New(),
+		protected:      list.New(),
+		elements:       make(map[*LRUHandle[K, V]]*list.Element),
+	}, nil
+}
+
+// 开启分段模式：即SetPolicy(NewSLRUPolicy(protectedRatio))
+// cache中已存在的entry按照当前的使用顺序全部进入probation段
+// protectedRatio无效时返回ErrInvalidRatio 不改变当前的策略
+func (p *LRUCache[K, V]) SetSegmented(protectedRatio float64) error {
+	policy, err := NewSLRUPolicy[K, V](protectedRatio)
+	if err != nil {
+		return err
+	}
+	p.SetPolicy(policy)
+	return nil
+}
+
+// 是否开启了分段模式
+func (p *LRUCache[K, V]) Segmented() bool {
+	return p.slru() != nil
+}
+
+// 分段的占用情况：各段的entry个数以及size
+// 未开启分段模式时均返回0
+func (p *LRUCache[K, V]) SegmentStats() (probationLen, probationSize, protectedLen, protectedSize int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if s := p.slru_(); s != nil {
+		return s.Stats()
+	}
+	return
+}
+
+// cache使用的SLRU策略(包括作为W-TinyLFU的main)
+func (p *LRUCache[K, V]) slru() *SLRUPolicy[K, V] {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.slru_()
+}
+
+// 必须持有锁
+func (p *_LRUCache[K, V]) slru_() *SLRUPolicy[K, V] {
+	switch policy := p.policy.(type) {
+	case *SLRUPolicy[K, V]:
+		return policy
+	case *TinyLFUPolicy[K, V]:
+		s, _ := policy.main.(*SLRUPolicy[K, V])
+		return s
+	}
+	return nil
+}
+
+// 各段的entry个数以及size
+func (s *SLRUPolicy[K, V]) Stats() (probationLen, probationSize, protectedLen, protectedSize int64) {
+	return int64(s.probation.Len()), s.probationSize, int64(s.protected.Len()), s.protectedSize
+}
+
+// 段中的entry
+type slruEntry[K comparable, V any] struct {
+	h         *LRUHandle[K, V]
+	protected bool
+}
+
+// 新的entry进入probation段
+func (s *SLRUPolicy[K, V]) Insert(h *LRUHandle[K, V]) {
+	s.elements[h] = s.probation.PushFront(&slruEntry[K, V]{h: h})
+	s.probationSize += h.size
+}
+
+// entry被访问：probation段中的entry晋升到protected段 protected段中的entry移动到表头
+func (s *SLRUPolicy[K, V]) Access(h *LRUHandle[K, V]) {
+	element := s.elements[h]
+	if element == nil {
+		return
+	}
+	e := element.Value.(*slruEntry[K, V])
+	if e.protected {
+		s.protected.MoveToFront(element)
+		return
+	}
+
+	s.probation.Remove(element)
+	s.probationSize -= h.size
+	e.protected = true
+	s.elements[h] = s.protected.PushFront(e)
+	s.protectedSize += h.size
+
+	s.demote()
+}
+
+// cache的capacity改变：protected段容量随之改变
+func (s *SLRUPolicy[K, V]) SetCapacity(capacity int64) {
+	s.protectedLimit = int64(float64(capacity) * s.protectedRatio)
+	s.demote()
+}
+
+// protected段超出容量：最旧的entry降级回probation段 至少保留一个entry
+func (s *SLRUPolicy[K, V]) demote() {
+	for s.protectedSize > s.protectedLimit && s.protected.Len() > 1 {
+		d := s.protected.Remove(s.protected.Back()).(*slruEntry[K, V])
+		s.protectedSize -= d.h.size
+		d.protected = false
+		s.elements[d.h] = s.probation.PushFront(d)
+		s.probationSize += d.h.size
+	}
+}
+
+// entry被移出cache
+func (s *SLRUPolicy[K, V]) Remove(h *LRUHandle[K, V]) {
+	element := s.elements[h]
+	if element == nil {
+		return
+	}
+	if element.Value.(*slruEntry[K, V]).protected {
+		s.protected.Remove(element)
+		s.protectedSize -= h.size
+	} else {
+		s.probation.Remove(element)
+		s.probationSize -= h.size
+	}
+	delete(s.elements, h)
+}
+
+// 选择淘汰的entry：probation段最旧的entry 若probation段为空则选择protected段最旧的entry
+func (s *SLRUPolicy[K, V]) Victim() *LRUHandle[K, V] {
+	if back := s.probation.Back(); back != nil {
+		return back.Value.(*slruEntry[K, V]).h
+	}
+	if back := s.protected.Back(); back != nil {
+		return back.Value.(*slruEntry[K, V]).h
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func newSegmented(t *testing.T, capacity int64) *LRUCache[string, int] {
+	t.Helper()
+	c, err := NewLRUCache[string, int](capacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetSegmented(DefaultProtectedRatio); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func checkSegments(t *testing.T, c *LRUCache[string, int], probation, protected int64) {
+	t.Helper()
+	if probLen, _, protLen, _ := c.SegmentStats(); probLen != probation || protLen != protected {
+		t.Fatalf("SegmentStats() = %d probation, %d protected, want %d, %d", probLen, protLen, probation, protected)
+	}
+}
+
+// 新entry进入probation段 再次命中才晋升到protected段
+func TestSLRUPromotion(t *testing.T) {
+	c := newSegmented(t, 10)
+	for i, key := range []string{"a", "b", "c"} {
+		c.Set(key, i, 1)
+	}
+	checkSegments(t, c, 3, 0)
+
+	c.Get("a")
+	checkSegments(t, c, 2, 1)
+	c.Get("a") // 已在protected段
+	checkSegments(t, c, 2, 1)
+	c.Get("b")
+	checkSegments(t, c, 1, 2)
+
+	c.Set("a", 10, 1) // 替换的entry重新进入probation段
+	checkSegments(t, c, 2, 1)
+}
+
+// protected段容量为capacity * ratio：cache未满时不降级
+func TestSLRUProtectedLimit(t *testing.T) {
+	c := newSegmented(t, 10) // protected段容量8
+	for i := 0; i < 5; i++ {
+		key := strconv.Itoa(i)
+		c.Set(key, i, 1)
+		c.Get(key)
+	}
+	checkSegments(t, c, 0, 5)
+
+	for i := 5; i < 10; i++ {
+		key := strconv.Itoa(i)
+		c.Set(key, i, 1)
+		c.Get(key)
+	}
+	checkSegments(t, c, 2, 8) // 最旧的0、1被降级
+	if _, _, protLen, protSize := c.SegmentStats(); protLen != 8 || protSize != 8 {
+		t.Fatalf("protected = %d entries, %d bytes, want 8, 8", protLen, protSize)
+	}
+
+	if err := c.SetCapacity(5); err != nil { // protected段容量4
+		t.Fatal(err)
+	}
+	checkSegments(t, c, 1, 4)
+	if c.Length() != 5 {
+		t.Fatalf("Length after SetCapacity(5) = %d, want 5", c.Length())
+	}
+}
+
+// 只访问一次的扫描只冲刷probation段
+func TestSLRUScanResistance(t *testing.T) {
+	c := newSegmented(t, 10)
+	hot := []string{"h0", "h1", "h2", "h3", "h4"}
+	for i, key := range hot {
+		c.Set(key, i, 1)
+		c.Get(key)
+	}
+	for i := 0; i < 1000; i++ {
+		c.Set("scan"+strconv.Itoa(i), i, 1)
+	}
+
+	for _, key := range hot {
+		if _, ok := c.Entry(key); !ok {
+			t.Errorf("hot key %q evicted by a scan", key)
+		}
+	}
+	checkSegments(t, c, 5, 5)
+}
@@ -0,0 +1,324 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// ========================================Codec=====================================
+
+// 快照中key、value的序列化方式
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte, v *T) error
+}
+
+// 基于encoding/gob的Codec：快照默认使用
+// 注：V为interface{}时 value的具体类型需要先gob.Register
+func GobCodec[T any]() Codec[T] {
+	return gobCodec[T]{}
+}
+
+type gobCodec[T any] struct{}
+
+func (gobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec[T]) Unmarshal(data []byte, v *T) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// 设置快照中value的序列化方式 nil表示使用gob
+// key始终使用gob
+func (p *LRUCache[K, V]) SetValueCodec(codec Codec[V]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.codec = codec
+}
+
+// 必须持有锁
+func (p *_LRUCache[K, V]) valueCodec() Codec[V] {
+	if p.codec != nil {
+		return p.codec
+	}
+	return GobCodec[V]()
+}
+
+// ========================================快照格式=====================================
+
+// 快照格式：
+//
+//	header: magic(8) version(4) count(8) crc32(4)
+//	record: length(4) body(length) crc32(4)   共count个 按从旧到新的顺序
+//	body:   size(8) created(8) accessed(8) expires(8) idle(8) keyLen(4) key valueLen(4) value
+//
+// 整数均为大端序 时间为UnixNano(expires为0表示没有设置ttl) crc32均为IEEE
+const (
+	snapshotMagic   = "LRUSNAP\x00"
+	snapshotVersion = 1
+
+	snapshotHeaderLen = 8 + 4 + 8 + 4
+	maxSnapshotRecord = 1 << 30 // 单条记录的长度上限 防止损坏的文件导致分配过大的内存
+)
+
+// 快照中的一个entry
+type snapshotRecord[K comparable, V any] struct {
+	key      K
+	value    V
+	size     int64
+	created  time.Time
+	accessed time.Time
+	expires  time.Time
+	idle     time.Duration
+}
+
+// 将cache中所有entry按照从旧到新的顺序写入w
+// 保留每个entry的size、创建时间、最后访问时间以及有效期 不包括deleter
+// 注：只在收集entry时持有锁 序列化和写入在锁外进行(期间entry被retain 不会被deleter释放)
+func (p *LRUCache[K, V]) Snapshot(w io.Writer) (err error) {
+	p.mu.Lock()
+	handles := make([]*LRUHandle[K, V], 0, len(p.table))
+	for element := p.list.Back(); element != nil; element = element.Prev() {
+		h := element.Value.(*LRUHandle[K, V])
+		p.addref(h)
+		handles = append(handles, h)
+	}
+	codec := p.valueCodec()
+	p.mu.Unlock()
+
+	defer p.releaseHandles(handles)
+
+	bw := bufio.NewWriter(w)
+	header := make([]byte, snapshotHeaderLen)
+	copy(header, snapshotMagic)
+	binary.BigEndian.PutUint32(header[8:], snapshotVersion)
+	binary.BigEndian.PutUint64(header[12:], uint64(len(handles)))
+	binary.BigEndian.PutUint32(header[20:], crc32.ChecksumIEEE(header[:20]))
+	if _, err = bw.Write(header); err != nil {
+		return err
+	}
+
+	keyCodec := GobCodec[K]()
+	var body []byte
+	for _, h := range handles {
+		if body, err = encodeRecord(body[:0], h, keyCodec, codec); err != nil {
+			return err
+		}
+
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], uint32(len(body)))
+		if _, err = bw.Write(buf[:]); err != nil {
+			return err
+		}
+		if _, err = bw.Write(body); err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint32(buf[:], crc32.ChecksumIEEE(body))
+		if _, err = bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// 从Snapshot写入的数据中恢复entry
+// 先读取并校验全部数据 任何错误(版本不符、校验失败、截断)都不会修改cache
+// 恢复的entry按照原有顺序成为cache中最新的entry 与已存在的key相同时替换之 已过期或超过capacity的entry被忽略
+// 恢复过程中超出capacity时按照淘汰策略淘汰
+func (p *LRUCache[K, V]) Restore(r io.Reader) error {
+	p.mu.Lock()
+	codec := p.valueCodec()
+	p.mu.Unlock()
+
+	records, err := readSnapshot[K, V](bufio.NewReader(r), codec)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrCacheClosed
+	}
+	now := time.Now()
+	for _, rec := range records {
+		p.insertRecord(rec, now)
+	}
+	return nil
+}
+
+// 将快照(或WAL)中的entry作为最新的entry插入cache 已过期或超过capacity的entry被忽略
+// 必须持有锁
+func (p *LRUCache[K, V]) insertRecord(rec snapshotRecord[K, V], now time.Time) {
+	if rec.size > p.capacity {
+		return
+	}
+	h := &LRUHandle[K, V]{
+		c:            p._LRUCache,
+		key:          rec.key,
+		value:        rec.value,
+		size:         rec.size,
+		time_created: rec.created,
+		refs:         1,
+		expires:      rec.expires,
+		idle:         rec.idle,
+	}
+	h.time_accessed.Store(rec.accessed.UnixNano())
+	if h.Expired(now) {
+		return
+	}
+	if h.expirable() {
+		p.expiring = true
+		p.startSweeper()
+	}
+
+	if element := p.table[rec.key]; element != nil {
+		p.removeElement(element, RemovalReplaced)
+		p.stats.replacements.Add(1)
+	}
+	p.stats.inserts.Add(1)
+
+	p.table[rec.key] = p.list.PushFront(h)
+	p.size += h.size
+	p.admit(h)
+	p.walPut(h)
+}
+
+// 将entry编码为快照记录的body 追加到buf
+func encodeRecord[K comparable, V any](buf []byte, h *LRUHandle[K, V], keyCodec Codec[K], codec Codec[V]) ([]byte, error) {
+	key, err := keyCodec.Marshal(h.key)
+	if err != nil {
+		return buf, fmt.Errorf("cache: encode key %v: %w", h.key, err)
+	}
+	value, err := codec.Marshal(h.value)
+	if err != nil {
+		return buf, fmt.Errorf("cache: encode value of %v: %w", h.key, err)
+	}
+
+	var expires int64
+	if !h.expires.IsZero() {
+		expires = h.expires.UnixNano()
+	}
+	buf = binary.BigEndian.AppendUint64(buf, uint64(h.size))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(h.time_created.UnixNano()))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(h.Time_Accessed().UnixNano()))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(expires))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(h.idle))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(key)))
+	buf = append(buf, key...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(value)))
+	buf = append(buf, value...)
+	if len(buf) > maxSnapshotRecord {
+		return buf, fmt.Errorf("cache: entry %v too large: %d bytes", h.key, len(buf))
+	}
+	return buf, nil
+}
+
+// 快照数据无效
+func snapshotError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalidSnapshot}, args...)...)
+}
+
+// 读取并校验全部记录
+func readSnapshot[K comparable, V any](r io.Reader, codec Codec[V]) ([]snapshotRecord[K, V], error) {
+	header := make([]byte, snapshotHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, snapshotError("read header: %v", err)
+	}
+	if string(header[:8]) != snapshotMagic {
+		return nil, snapshotError("bad magic")
+	}
+	if crc32.ChecksumIEEE(header[:20]) != binary.BigEndian.Uint32(header[20:]) {
+		return nil, snapshotError("header checksum mismatch")
+	}
+	if version := binary.BigEndian.Uint32(header[8:]); version != snapshotVersion {
+		return nil, snapshotError("unsupported version %d", version)
+	}
+	count := binary.BigEndian.Uint64(header[12:])
+
+	keyCodec := GobCodec[K]()
+	records := make([]snapshotRecord[K, V], 0, min(count, 1<<16))
+	var buf [4]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, snapshotError("record %d: %v", i, err)
+		}
+		n := binary.BigEndian.Uint32(buf[:])
+		if n > maxSnapshotRecord {
+			return nil, snapshotError("record %d: length %d too large", i, n)
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, snapshotError("record %d: %v", i, err)
+		}
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, snapshotError("record %d: %v", i, err)
+		}
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(buf[:]) {
+			return nil, snapshotError("record %d: checksum mismatch", i)
+		}
+
+		rec, err := decodeRecord(body, keyCodec, codec)
+		if err != nil {
+			return nil, snapshotError("record %d: %v", i, err)
+		}
+		records = append(records, rec)
+	}
+
+	// 记录之后不应还有数据
+	if n, _ := r.Read(buf[:1]); n > 0 {
+		return nil, snapshotError("trailing data after %d records", count)
+	}
+	return records, nil
+}
+
+func decodeRecord[K comparable, V any](body []byte, keyCodec Codec[K], codec Codec[V]) (rec snapshotRecord[K, V], err error) {
+	const fixed = 5*8 + 4
+	if len(body) < fixed {
+		return rec, io.ErrUnexpectedEOF
+	}
+	rec.size = int64(binary.BigEndian.Uint64(body[0:]))
+	rec.created = time.Unix(0, int64(binary.BigEndian.Uint64(body[8:])))
+	rec.accessed = time.Unix(0, int64(binary.BigEndian.Uint64(body[16:])))
+	if expires := int64(binary.BigEndian.Uint64(body[24:])); expires != 0 {
+		rec.expires = time.Unix(0, expires)
+	}
+	rec.idle = time.Duration(binary.BigEndian.Uint64(body[32:]))
+	if rec.size <= 0 {
+		return rec, fmt.Errorf("invalid size %d", rec.size)
+	}
+
+	body = body[40:]
+	keyLen := int(binary.BigEndian.Uint32(body))
+	body = body[4:]
+	if keyLen > len(body)-4 {
+		return rec, io.ErrUnexpectedEOF
+	}
+	if err = keyCodec.Unmarshal(body[:keyLen], &rec.key); err != nil {
+		return rec, fmt.Errorf("decode key: %v", err)
+	}
+	body = body[keyLen:]
+
+	valueLen := int(binary.BigEndian.Uint32(body))
+	body = body[4:]
+	if valueLen != len(body) {
+		return rec, io.ErrUnexpectedEOF
+	}
+	if err = codec.Unmarshal(body, &rec.value); err != nil {
+		return rec, fmt.Errorf("decode value: %v", err)
+	}
+	return rec, nil
+}
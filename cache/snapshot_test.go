@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+	"time"
+)
+
+func snapshotOf(t *testing.T, c *LRUCache[string, int]) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	src, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	src.Set("a", 1, 3)
+	src.SetWithOptions("b", 2, 1, nil, WithTTL(time.Hour))
+	src.SetWithOptions("c", 3, 1, nil, WithIdleTimeout(time.Hour))
+	src.Get("a") // 顺序：b c a
+	data := snapshotOf(t, src)
+
+	dst, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	dst.Set("a", 100, 1) // 被替换
+	if err := dst.Restore(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := dst.Keys(), src.Keys(); !equalKeys(got, want) {
+		t.Errorf("Keys after Restore = %v, want %v", got, want)
+	}
+	for _, key := range src.Keys() {
+		want, _ := src.Entry(key)
+		got, ok := dst.Entry(key)
+		if !ok {
+			t.Errorf("%q not restored", key)
+			continue
+		}
+		if got.Size != want.Size || !got.Created.Equal(want.Created) || !got.Expires.Equal(want.Expires) || got.Idle != want.Idle {
+			t.Errorf("Entry(%q) = %+v, want %+v", key, got, want)
+		}
+		if v, _ := dst.Get(key); v != src.Value(key) {
+			t.Errorf("Get(%q) = %d, want %d", key, v, src.Value(key))
+		}
+	}
+	if dst.Size() != src.Size() {
+		t.Errorf("Size = %d, want %d", dst.Size(), src.Size())
+	}
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 无效的快照返回ErrInvalidSnapshot 且不修改cache
+func TestRestoreInvalidSnapshot(t *testing.T) {
+	src, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	src.Set("a", 1, 1)
+	src.Set("b", 2, 1)
+	data := snapshotOf(t, src)
+
+	versioned := append([]byte(nil), data...) // 校验和正确但版本不符
+	binary.BigEndian.PutUint32(versioned[8:], snapshotVersion+1)
+	binary.BigEndian.PutUint32(versioned[20:], crc32.ChecksumIEEE(versioned[:20]))
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-6] ^= 0xff
+
+	for name, input := range map[string][]byte{
+		"empty":            nil,
+		"truncated header": data[:snapshotHeaderLen-1],
+		"truncated record": data[:len(data)-3],
+		"missing record":   data[:snapshotHeaderLen+4],
+		"version mismatch": versioned,
+		"bad magic":        append([]byte("XXXXXXXX"), data[8:]...),
+		"bad checksum":     corrupt,
+		"trailing data":    append(append([]byte(nil), data...), 0),
+	} {
+		dst, err := NewLRUCache[string, int](100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst.Set("x", 9, 1)
+		if err := dst.Restore(bytes.NewReader(input)); !errors.Is(err, ErrInvalidSnapshot) {
+			t.Errorf("%s: Restore = %v, want ErrInvalidSnapshot", name, err)
+		}
+		if keys := dst.Keys(); len(keys) != 1 || keys[0] != "x" {
+			t.Errorf("%s: cache modified by failed Restore: %v", name, keys)
+		}
+		dst.Close()
+	}
+}
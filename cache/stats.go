@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// cache的运行计数 均为原子操作
+type counters struct {
+	hits         atomic.Int64 // Lookup命中
+	misses       atomic.Int64 // Lookup未命中(包括已过期)
+	inserts      atomic.Int64 // 插入(包括替换)
+	replacements atomic.Int64 // 插入时替换了已存在的key
+	evictions    atomic.Int64 // 因容量不足被淘汰
+	expirations  atomic.Int64 // 因过期被回收
+	erases       atomic.Int64 // 被Erase、RemoveFront、RemoveBack删除
+	loads        atomic.Int64 // GetFrom调用getter的次数
+	loadErrors   atomic.Int64 // getter返回错误的次数
+	loadTime     atomic.Int64 // getter的总耗时(ns)
+}
+
+// 统计信息快照
+type StatsSnapshot struct {
+	Length       int64
+	Size         int64
+	Capacity     int64
+	OldestAccess time.Time
+
+	Hits          int64
+	Misses        int64
+	Inserts       int64
+	Replacements  int64
+	Evictions     int64
+	Expirations   int64
+	Erases        int64
+	Loads         int64
+	LoadErrors    int64
+	TotalLoadTime time.Duration
+
+	// 分段模式下各段的占用情况
+	ProbationLength int64 `json:",omitempty"`
+	ProbationSize   int64 `json:",omitempty"`
+	ProtectedLength int64 `json:",omitempty"`
+	ProtectedSize   int64 `json:",omitempty"`
+}
+
+// 命中率：hits / (hits + misses) 没有任何Lookup时返回0
+func (s StatsSnapshot) HitRatio() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// 平均每次getter的耗时
+func (s StatsSnapshot) AverageLoadTime() time.Duration {
+	if s.Loads > 0 {
+		return s.TotalLoadTime / time.Duration(s.Loads)
+	}
+	return 0
+}
+
+// 累加另一个快照 用于汇总多个cache(如ShardedLRUCache的各个分片)
+func (s *StatsSnapshot) add(o StatsSnapshot) {
+	s.Length += o.Length
+	s.Size += o.Size
+	s.Capacity += o.Capacity
+	if !o.OldestAccess.IsZero() && (s.OldestAccess.IsZero() || o.OldestAccess.Before(s.OldestAccess)) {
+		s.OldestAccess = o.OldestAccess
+	}
+
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Inserts += o.Inserts
+	s.Replacements += o.Replacements
+	s.Evictions += o.Evictions
+	s.Expirations += o.Expirations
+	s.Erases += o.Erases
+	s.Loads += o.Loads
+	s.LoadErrors += o.LoadErrors
+	s.TotalLoadTime += o.TotalLoadTime
+
+	s.ProbationLength += o.ProbationLength
+	s.ProbationSize += o.ProbationSize
+	s.ProtectedLength += o.ProtectedLength
+	s.ProtectedSize += o.ProtectedSize
+}
+
+// entry的元数据
+type EntryInfo struct {
+	Key      interface{}
+	Size     int64
+	Created  time.Time
+	Accessed time.Time
+	Accesses int64         // 被Lookup命中的次数
+	Refs     int           // 调用方持有的引用数(不包括cache自身)
+	Expires  time.Time     // IsZero()表示不过期
+	Idle     time.Duration // 0表示不限制
+	Merged   int64         // 加载时被合并的GetFrom调用次数
+}
+
+// 查询entry的元数据 不影响entry的LRU顺序及统计
+func (p *LRUCache[K, V]) Entry(key K) (info EntryInfo, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element := p.table[key]
+	if element == nil {
+		return info, false
+	}
+	h := element.Value.(*LRUHandle[K, V])
+	return EntryInfo{
+		Key:      h.key,
+		Size:     h.size,
+		Created:  h.time_created,
+		Accessed: h.Time_Accessed(),
+		Accesses: h.Accesses(),
+		Refs:     int(atomic.LoadUint32(&h.refs)) - 1,
+		Expires:  h.expires,
+		Idle:     h.idle,
+		Merged:   h.Merged(),
+	}, true
+}
+
+// 统计信息快照
+func (p *LRUCache[K, V]) StatsSnapshot() (s StatsSnapshot) {
+	s.Length, s.Size, s.Capacity, s.OldestAccess = p.Stats()
+	s.ProbationLength, s.ProbationSize, s.ProtectedLength, s.ProtectedSize = p.SegmentStats()
+
+	s.Hits = p.stats.hits.Load()
+	s.Misses = p.stats.misses.Load()
+	s.Inserts = p.stats.inserts.Load()
+	s.Replacements = p.stats.replacements.Load()
+	s.Evictions = p.stats.evictions.Load()
+	s.Expirations = p.stats.expirations.Load()
+	s.Erases = p.stats.erases.Load()
+	s.Loads = p.stats.loads.Load()
+	s.LoadErrors = p.stats.loadErrors.Load()
+	s.TotalLoadTime = time.Duration(p.stats.loadTime.Load())
+	return s
+}
+
+// 重置所有计数 不影响cache中的entry
+func (p *LRUCache[K, V]) ResetStats() {
+	p.stats.hits.Store(0)
+	p.stats.misses.Store(0)
+	p.stats.inserts.Store(0)
+	p.stats.replacements.Store(0)
+	p.stats.evictions.Store(0)
+	p.stats.expirations.Store(0)
+	p.stats.erases.Store(0)
+	p.stats.loads.Store(0)
+	p.stats.loadErrors.Store(0)
+	p.stats.loadTime.Store(0)
+}
+
+// 命中率
+func (p *LRUCache[K, V]) HitRatio() float64 {
+	hits, misses := p.stats.hits.Load(), p.stats.misses.Load()
+	if total := hits + misses; total > 0 {
+		return float64(hits) / float64(total)
+	}
+	return 0
+}
+
+// 统计信息json格式
+// 分段模式下额外输出各段的占用情况
+func (p *LRUCache[K, V]) StatsJSON() string {
+	if p == nil {
+		return "{}"
+	}
+	return statsJSON(p.StatsSnapshot())
+}
+
+// 在快照的基础上额外输出HitRatio
+func statsJSON(s StatsSnapshot) string {
+	b, err := json.MarshalIndent(struct {
+		StatsSnapshot
+		HitRatio float64
+	}{s, s.HitRatio()}, "", "\t")
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
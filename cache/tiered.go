@@ -0,0 +1,542 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 两级cache：内存(LRUCache) + 磁盘
+// 因容量不足被内存淘汰的entry被序列化写入磁盘层(其他原因的移除如Erase、过期不会写入) 关闭时仍在内存层中的entry同样写入
+// 磁盘层有独立的容量(字节) 按访问顺序(LRU)淘汰：写入或命中的entry移动到表头 磁盘空间不足时删除最久未访问的entry
+// Lookup在内存未命中时查询磁盘层 命中的entry被复制回内存 磁盘层保留其文件：之后再次被内存淘汰时覆盖同一文件
+// 磁盘层的每个entry为一个文件 文件的修改时间为entry最近一次访问的时间
+// 重启时扫描目录按修改时间重建索引(即恢复访问顺序) 损坏或已过期的文件被删除
+//
+// 写入磁盘在后台进行：被淘汰的entry在写入完成前暂存于内存(pending) 期间仍可被查询
+// value的序列化方式同快照 见LRUCache.SetValueCodec
+// 注：不支持deleter；锁的顺序为 内存层的锁 -> TieredCache的锁
+type TieredCache[K comparable, V any] struct {
+	mem *LRUCache[K, V]
+
+	mu       sync.Mutex
+	flushMu  sync.Mutex // 同一时刻只有一个flush
+	dir      string
+	pending  map[K]*LRUHandle[K, V] // 已被内存淘汰 尚未写入磁盘
+	disk     *list.List             // 存放*tierEntry[K] 表头为最近访问 LRU
+	table    map[K]*list.Element
+	claims   map[K]*tierEntry[K] // 正在被Lookup移回内存的entry
+	size     int64               // 磁盘层的总字节数
+	capacity int64
+	stats    DiskStats
+	closed   bool
+
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// 磁盘层的一个entry
+type tierEntry[K comparable] struct {
+	key  K
+	file string
+	size int64 // 文件的字节数
+}
+
+// 磁盘层的统计信息
+type DiskStats struct {
+	Length    int64
+	Size      int64
+	Capacity  int64
+	Hits      int64 // Lookup在磁盘层(包括pending)命中
+	Spills    int64 // 写入磁盘的entry
+	Evictions int64 // 因磁盘容量不足被删除
+	Errors    int64 // 写入或读取失败(entry被丢弃)
+}
+
+// 磁盘文件：magic(8) body crc32(4) body同快照记录的body
+const (
+	tierMagic   = "LRUTIER\x00"
+	tierFileExt = ".entry"
+	tierTempExt = ".tmp"
+)
+
+// 创建两级cache：内存层容量为memCapacity 磁盘层位于dir 容量为diskCapacity字节
+// dir中已有的entry被重新加载到磁盘层
+func NewTieredCache[K comparable, V any](memCapacity int64, dir string, diskCapacity int64) (*TieredCache[K, V], error) {
+	if err := validateCapacity(diskCapacity); err != nil {
+		return nil, err
+	}
+	mem, err := NewLRUCache[K, V](memCapacity)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	t := &TieredCache[K, V]{
+		mem:      mem,
+		dir:      dir,
+		pending:  make(map[K]*LRUHandle[K, V]),
+		disk:     list.New(),
+		table:    make(map[K]*list.Element),
+		claims:   make(map[K]*tierEntry[K]),
+		capacity: diskCapacity,
+		notify:   make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err = t.load(); err != nil {
+		mem.Close()
+		return nil, err
+	}
+
+	mem.mu.Lock()
+	mem.spill = t.spilled
+	mem.mu.Unlock()
+
+	go t.writer()
+	return t, nil
+}
+
+// 内存层：可用于设置淘汰策略、value的序列化方式、统计等
+// 注：直接对内存层的Set、Erase不会更新磁盘层中相同key的entry
+func (t *TieredCache[K, V]) Memory() *LRUCache[K, V] {
+	return t.mem
+}
+
+// 设置 同时删除磁盘层中相同key的entry
+func (t *TieredCache[K, V]) Set(key K, value V, size int, opts ...EntryOption) error {
+	t.forget(key)
+	return t.mem.SetWithOptions(key, value, size, nil, opts...)
+}
+
+// 查询
+func (t *TieredCache[K, V]) Get(key K) (value V, ok bool) {
+	if v, h, ok := t.Lookup_(key); ok {
+		h.Close()
+		return v, true
+	}
+	return
+}
+
+// 查询 内存层未命中时查询磁盘层 命中后移回内存层
+func (t *TieredCache[K, V]) Lookup(key K) (value V, handle io.Closer, ok bool) {
+	if v, h, ok := t.Lookup_(key); ok {
+		return v, h, true
+	}
+	return
+}
+
+func (t *TieredCache[K, V]) Lookup_(key K) (value V, handle *LRUHandle[K, V], ok bool) {
+	if value, handle, ok = t.mem.Lookup_(key); ok {
+		return
+	}
+	if t.promote(key) {
+		return t.mem.Lookup_(key)
+	}
+	return
+}
+
+// 删除 包括磁盘层
+func (t *TieredCache[K, V]) Erase(key K) {
+	t.forget(key)
+	t.mem.Erase(key)
+}
+
+// 清除两级cache
+func (t *TieredCache[K, V]) Clear() {
+	t.mem.Clear()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clear(t.pending)
+	clear(t.claims)
+	for t.disk.Len() > 0 {
+		t.removeEntry(t.disk.Back())
+	}
+}
+
+// 磁盘层的统计信息
+func (t *TieredCache[K, V]) DiskStats() DiskStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stats
+	s.Length, s.Size, s.Capacity = int64(t.disk.Len()), t.size, t.capacity
+	return s
+}
+
+// 将已被内存淘汰的entry全部写入磁盘
+func (t *TieredCache[K, V]) Flush() error {
+	return t.flush()
+}
+
+// 关闭：关闭内存层 然后将被淘汰以及仍在内存层中的entry全部写入磁盘 重新打开后可以查询
+func (t *TieredCache[K, V]) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.mu.Unlock()
+
+	// 先关闭内存层：仍在内存中的entry随之加入pending 之后不会再有新的entry
+	memErr := t.mem.Close()
+
+	close(t.stop)
+	<-t.done
+	err := t.flush()
+
+	t.mem.mu.Lock()
+	t.mem.spill = nil
+	t.mem.mu.Unlock()
+	return errors.Join(err, memErr)
+}
+
+// ========================================内部实现=====================================
+
+// 内存层淘汰entry或关闭时回调 此时持有内存层的锁
+func (t *TieredCache[K, V]) spilled(h *LRUHandle[K, V]) {
+	if h.Expired(time.Now()) {
+		return
+	}
+	t.mu.Lock()
+	t.pending[h.key] = h
+	t.mu.Unlock()
+
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 删除key在磁盘层(及pending)中的entry
+func (t *TieredCache[K, V]) forget(key K) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pending, key)
+	if e := t.claims[key]; e != nil {
+		delete(t.claims, key)
+		os.Remove(e.file)
+	}
+	if element := t.table[key]; element != nil {
+		t.removeEntry(element)
+	}
+}
+
+// 将key从pending或磁盘层移回内存层 返回是否找到
+func (t *TieredCache[K, V]) promote(key K) bool {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return false
+	}
+	if h := t.pending[key]; h != nil {
+		t.mu.Unlock()
+		return t.restore(key, func() bool {
+			if t.pending[key] != h {
+				return false
+			}
+			delete(t.pending, key)
+			t.stats.Hits++
+			return true
+		}, recordOf(h))
+	}
+
+	element := t.table[key]
+	if element == nil {
+		t.mu.Unlock()
+		return false
+	}
+	// 移出索引(避免读取期间因磁盘容量不足被删除)
+	e := element.Value.(*tierEntry[K])
+	t.disk.Remove(element)
+	delete(t.table, key)
+	t.size -= e.size
+	t.claims[key] = e
+	t.mu.Unlock()
+
+	t.mem.mu.Lock()
+	codec := t.mem.valueCodec()
+	t.mem.mu.Unlock()
+
+	rec, err := readTierFile[K, V](e.file, codec)
+	if err == nil && rec.key != key {
+		err = fmt.Errorf("cache: %s holds a different key", e.file)
+	}
+	if err != nil || rec.expired(time.Now()) {
+		t.mu.Lock()
+		if t.claims[key] == e { // 未被Set/Erase删除：文件损坏或entry已过期
+			delete(t.claims, key)
+			os.Remove(e.file)
+			if err != nil {
+				t.stats.Errors++
+			}
+		}
+		t.mu.Unlock()
+		return false
+	}
+
+	// 磁盘层保留文件并移动到表头：插入内存后entry可能立即被淘汰 此时覆盖同一文件
+	return t.restore(key, func() bool {
+		if t.claims[key] != e {
+			return false
+		}
+		delete(t.claims, key)
+		if t.table[key] == nil { // 读取期间可能已由flush写入同名文件
+			t.table[key] = t.disk.PushFront(e)
+			t.size += e.size
+			now := time.Now()
+			os.Chtimes(e.file, now, now)
+		}
+		t.stats.Hits++
+		return true
+	}, rec)
+}
+
+// 将rec插入内存层：valid在同时持有两把锁时检查entry是否仍有效(未被Set/Erase删除)
+// 内存层中已存在key(如并发的Set)时不覆盖
+func (t *TieredCache[K, V]) restore(key K, valid func() bool, rec snapshotRecord[K, V]) bool {
+	p := t.mem
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t.mu.Lock()
+	ok := valid()
+	t.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	if p.closed || p.table[key] != nil {
+		return !p.closed
+	}
+	p.insertRecord(rec, time.Now()) // 已过期或超过内存层容量的entry被丢弃
+	return p.table[key] != nil
+}
+
+func recordOf[K comparable, V any](h *LRUHandle[K, V]) snapshotRecord[K, V] {
+	return snapshotRecord[K, V]{
+		key:      h.key,
+		value:    h.value,
+		size:     h.size,
+		created:  h.time_created,
+		accessed: h.Time_Accessed(),
+		expires:  h.expires,
+		idle:     h.idle,
+	}
+}
+
+// 后台写入磁盘
+func (t *TieredCache[K, V]) writer() {
+	defer close(t.done)
+	for {
+		select {
+		case <-t.notify:
+			t.flush()
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// 写入pending中的entry 只在取出pending和更新索引时持有锁
+func (t *TieredCache[K, V]) flush() error {
+	t.flushMu.Lock()
+	defer t.flushMu.Unlock()
+
+	t.mem.mu.Lock()
+	codec := t.mem.valueCodec()
+	t.mem.mu.Unlock()
+
+	t.mu.Lock()
+	handles := make([]*LRUHandle[K, V], 0, len(t.pending))
+	for _, h := range t.pending {
+		handles = append(handles, h)
+	}
+	t.mu.Unlock()
+	// 按访问时间从旧到新写入：最近访问的entry位于表头
+	sort.Slice(handles, func(i, j int) bool { return handles[i].Time_Accessed().Before(handles[j].Time_Accessed()) })
+
+	var errs []error
+	keyCodec := GobCodec[K]()
+	for _, h := range handles {
+		file := t.fileName(h.key, keyCodec)
+		size, err := writeTierFile(file, h, keyCodec, codec)
+
+		t.mu.Lock()
+		switch {
+		case t.pending[h.key] != h: // 写入期间被移回内存或被Set/Erase删除
+			if err == nil && t.table[h.key] == nil && t.claims[h.key] == nil {
+				os.Remove(file)
+			}
+		case err != nil:
+			delete(t.pending, h.key)
+			t.stats.Errors++
+			errs = append(errs, err)
+		default:
+			delete(t.pending, h.key)
+			if element := t.table[h.key]; element != nil { // 文件已被覆盖
+				e := element.Value.(*tierEntry[K])
+				t.size -= e.size
+				t.disk.Remove(element)
+				delete(t.table, h.key)
+			}
+			t.table[h.key] = t.disk.PushFront(&tierEntry[K]{key: h.key, file: file, size: size})
+			t.size += size
+			t.stats.Spills++
+			t.checkCapacity()
+		}
+		t.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// 磁盘空间不足时删除最久未访问的entry(LRU) 必须持有锁
+func (t *TieredCache[K, V]) checkCapacity() {
+	for t.size > t.capacity && t.disk.Len() > 0 {
+		t.removeEntry(t.disk.Back())
+		t.stats.Evictions++
+	}
+}
+
+// 必须持有锁
+func (t *TieredCache[K, V]) removeEntry(element *list.Element) {
+	e := element.Value.(*tierEntry[K])
+	t.disk.Remove(element)
+	delete(t.table, e.key)
+	t.size -= e.size
+	os.Remove(e.file)
+}
+
+// entry的文件名：key的sha256
+func (t *TieredCache[K, V]) fileName(key K, keyCodec Codec[K]) string {
+	b, err := keyCodec.Marshal(key)
+	if err != nil { // 无法编码的key：写入时同样会失败
+		b = []byte(fmt.Sprint(key))
+	}
+	sum := sha256.Sum256(b)
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:16])+tierFileExt)
+}
+
+// 重建索引：按照文件的修改时间(即访问时间)从旧到新加入 损坏或已过期的文件被删除
+func (t *TieredCache[K, V]) load() error {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return err
+	}
+
+	type loaded struct {
+		entry   *tierEntry[K]
+		modTime time.Time
+	}
+	var files []loaded
+	now := time.Now()
+	codec := skipCodec[V]{} // 只需要key及有效期：value在移回内存时才解码(此时内存层的value codec已设置)
+	for _, de := range entries {
+		name := filepath.Join(t.dir, de.Name())
+		if strings.HasSuffix(de.Name(), tierTempExt) { // 写入时中断
+			os.Remove(name)
+			continue
+		}
+		if de.IsDir() || !strings.HasSuffix(de.Name(), tierFileExt) {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		rec, err := readTierFile[K, V](name, codec)
+		if err != nil || rec.expired(now) {
+			os.Remove(name)
+			continue
+		}
+		files = append(files, loaded{&tierEntry[K]{key: rec.key, file: name, size: info.Size()}, info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if element := t.table[f.entry.key]; element != nil { // 不应出现：文件名由key决定
+			t.removeEntry(element)
+		}
+		t.table[f.entry.key] = t.disk.PushFront(f.entry)
+		t.size += f.entry.size
+	}
+	t.checkCapacity()
+	return nil
+}
+
+// 不解码value
+type skipCodec[V any] struct{}
+
+func (skipCodec[V]) Marshal(V) ([]byte, error) {
+	return nil, errors.New("cache: skipCodec cannot marshal")
+}
+func (skipCodec[V]) Unmarshal([]byte, *V) error { return nil }
+
+// 记录是否已过期
+func (rec snapshotRecord[K, V]) expired(now time.Time) bool {
+	if !rec.expires.IsZero() && !now.Before(rec.expires) {
+		return true
+	}
+	return rec.idle > 0 && now.Sub(rec.accessed) >= rec.idle
+}
+
+// 写入临时文件后rename 文件的修改时间设为entry的访问时间 返回文件的字节数
+func writeTierFile[K comparable, V any](file string, h *LRUHandle[K, V], keyCodec Codec[K], codec Codec[V]) (int64, error) {
+	buf, err := encodeRecord([]byte(tierMagic), h, keyCodec, codec)
+	if err != nil {
+		return 0, err
+	}
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf[len(tierMagic):]))
+
+	tmp := strings.TrimSuffix(file, tierFileExt) + tierTempExt
+	if err = os.WriteFile(tmp, buf, 0o644); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	accessed := h.Time_Accessed()
+	if err = os.Chtimes(tmp, accessed, accessed); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err = os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return int64(len(buf)), nil
+}
+
+func readTierFile[K comparable, V any](file string, codec Codec[V]) (rec snapshotRecord[K, V], err error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return rec, err
+	}
+	if len(buf) < len(tierMagic)+4 || string(buf[:len(tierMagic)]) != tierMagic {
+		return rec, snapshotError("%s: bad magic", file)
+	}
+	body := buf[len(tierMagic) : len(buf)-4]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(buf[len(buf)-4:]) {
+		return rec, snapshotError("%s: checksum mismatch", file)
+	}
+	if rec, err = decodeRecord(body, GobCodec[K](), codec); err != nil {
+		return rec, snapshotError("%s: %v", file, err)
+	}
+	return rec, nil
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"testing"
+)
+
+func newTestTiered(t *testing.T, dir string, memCapacity, diskCapacity int64) *TieredCache[int, string] {
+	t.Helper()
+	c, err := NewTieredCache[int, string](memCapacity, dir, diskCapacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// 被内存淘汰的entry写入磁盘层 命中后移回内存层
+func TestTieredSpillAndPromote(t *testing.T) {
+	c := newTestTiered(t, t.TempDir(), 2, 1<<20)
+	defer c.Close()
+
+	for k := 0; k < 3; k++ {
+		if err := c.Set(k, "v", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Memory().Entry(0); ok {
+		t.Fatal("0 still in memory after eviction")
+	}
+	if s := c.DiskStats(); s.Length != 1 || s.Spills != 1 {
+		t.Fatalf("DiskStats = %+v, want Length 1, Spills 1", s)
+	}
+
+	if v, ok := c.Get(0); !ok || v != "v" {
+		t.Fatalf("Get(0) = %q, %v, want v, true", v, ok)
+	}
+	if _, ok := c.Memory().Entry(0); !ok {
+		t.Error("0 not promoted to memory")
+	}
+	c.Flush() // 移回时淘汰了其他entry 磁盘层保留0
+	if s := c.DiskStats(); s.Hits != 1 || s.Length != 2 || s.Spills != 2 {
+		t.Errorf("DiskStats after promotion = %+v, want Hits 1, Length 2, Spills 2", s)
+	}
+
+	c.Erase(1)
+	c.Erase(2)
+	c.Flush()
+	if _, ok := c.Get(1); ok {
+		t.Error("erased key found")
+	}
+	if _, ok := c.Get(2); ok {
+		t.Error("erased key found")
+	}
+}
+
+func onDisk[K comparable, V any](c *TieredCache[K, V], key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.table[key] != nil
+}
+
+// 磁盘空间不足时删除最久未访问的entry：磁盘层命中的entry移动到表头
+func TestTieredDiskLRU(t *testing.T) {
+	probe := newTestTiered(t, t.TempDir(), 1, 1<<20)
+	probe.Set(0, "v", 1)
+	probe.Set(1, "v", 1)
+	probe.Flush()
+	fileSize := probe.DiskStats().Size
+	probe.Close()
+
+	c := newTestTiered(t, t.TempDir(), 2, 3*fileSize) // 最多3个文件
+	defer c.Close()
+	for k := 0; k < 5; k++ { // 磁盘层：2 1 0 内存层：4 3
+		c.Set(k, "v", 1)
+		c.Flush()
+	}
+	if _, ok := c.Get(0); !ok { // 0移动到表头 内存层淘汰3：磁盘层 3 0 2 删除1
+		t.Fatal("0 not found on disk")
+	}
+	c.Flush()
+	c.Set(5, "v", 1) // 内存层淘汰4：磁盘层 4 3 0 删除2
+	c.Flush()
+
+	if s := c.DiskStats(); s.Length != 3 || s.Evictions != 2 {
+		t.Fatalf("DiskStats = %+v, want Length 3, Evictions 2", s)
+	}
+	for k, want := range map[int]bool{0: true, 1: false, 2: false, 3: true, 4: true, 5: false} {
+		if got := onDisk(c, k); got != want {
+			t.Errorf("key %d on disk = %v, want %v", k, got, want)
+		}
+	}
+}
+
+// 关闭时写入被淘汰以及仍在内存层中的entry 重新打开后可以查询 访问顺序不变
+func TestTieredReopen(t *testing.T) {
+	dir := t.TempDir()
+	const n, mem = 100, 10
+	c := newTestTiered(t, dir, mem, 1<<20)
+	for k := 0; k < n; k++ {
+		if err := c.Set(k, "v", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil { // 不调用Flush：由Close写入
+		t.Fatal(err)
+	}
+
+	c = newTestTiered(t, dir, n, 1<<20)
+	defer c.Close()
+	if s := c.DiskStats(); s.Length != n {
+		t.Fatalf("DiskStats.Length after reopen = %d, want %d", s.Length, n)
+	}
+	c.mu.Lock()
+	front, back := c.disk.Front().Value.(*tierEntry[int]).key, c.disk.Back().Value.(*tierEntry[int]).key
+	c.mu.Unlock()
+	if front != n-1 || back != 0 {
+		t.Errorf("disk order after reopen: front %d, back %d, want %d, 0", front, back, n-1)
+	}
+	for k := 0; k < n; k++ { // 包括关闭时在内存层中的entry
+		if v, ok := c.Get(k); !ok || v != "v" {
+			t.Fatalf("Get(%d) after reopen = %q, %v", k, v, ok)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"container/list"
+	"hash/maphash"
+)
+
+// 默认window段占capacity的比例
+const DefaultWindowRatio = 0.01
+
+// ========================================count-min sketch=====================================
+
+// sketch的行数：每个key在每一行对应一个4bit计数器(上限15)
+const sketchDepth = 4
+
+// 频率估计：count-min sketch
+// 每记录sampleSize次后所有计数器减半(aging) 使得频率估计偏向近期的访问
+type countMinSketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+// 每行计数器的个数上限
+const maxSketchWidth = 1 << 20
+
+// 根据预计的entry个数创建sketch
+// 每行的计数器个数为entries的16倍(降低hash冲突) 每记录10*entries次进行一次aging
+func newCountMinSketch(entries int) *countMinSketch {
+	entries = max(min(entries, maxSketchWidth), 1)
+	w := 16
+	for w < 16*entries && w < maxSketchWidth {
+		w <<= 1
+	}
+
+	s := &countMinSketch{
+		mask:       uint64(w - 1),
+		sampleSize: 10 * entries,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+// 第i行中hash对应计数器的下标：double hashing
+func (s *countMinSketch) index(hash uint64, i int) uint64 {
+	return (hash + uint64(i)*(hash>>32|1)) & s.mask
+}
+
+func (s *countMinSketch) increment(hash uint64) {
+	for i := range s.rows {
+		if idx := s.index(hash, i); s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+// 所有行中最小的计数即为估计的频率
+func (s *countMinSketch) estimate(hash uint64) uint8 {
+	min := uint8(15)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(hash, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// aging：所有计数器减半
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// ========================================W-TinyLFU=====================================
+
+// W-TinyLFU：包装main策略的准入策略
+// 新插入的entry先进入一个小的window段(LRU) window段溢出的entry进入main成为候选者
+// 需要淘汰时 候选者与main的victim比较估计的访问频率：
+// 只有候选者的频率更高时才被准入main(淘汰victim) 否则淘汰候选者
+// main为SLRU时即Caffeine的W-TinyLFU
+// See https://arxiv.org/abs/1512.00727
+// 同时实现了AdmissionPolicy：未命中的Lookup同样计入频率 新entry先进入window段再与victim比较
+type TinyLFUPolicy[K comparable, V any] struct {
+	main Policy[K, V]
+
+	sketch      *countMinSketch
+	seed        maphash.Seed
+	windowRatio float64 // window段容量 = 总size * windowRatio
+
+	window     *list.List // 存放*LRUHandle 表头为最近访问
+	windowSize int64
+	elements   map[*LRUHandle[K, V]]*list.Element // 位于window段中的entry
+
+	candidates *list.List // 最近离开window段、尚未与victim比较过的entry 表头为最新
+	candidate  map[*LRUHandle[K, V]]*list.Element
+
+	size int64 // 所有entry的总size
+
+	admitted int64 // 被准入main的候选者个数
+	rejected int64 // 被拒绝的候选者个数
+}
+
+// 创建W-TinyLFU策略
+// windowRatio为window段占所有entry总size的比例 取值(0, 1)
+// entries为预计的entry个数：决定sketch的大小
+// main为nil时使用LRU
+// windowRatio无效时返回ErrInvalidRatio
+func NewTinyLFUPolicy[K comparable, V any](windowRatio float64, entries int, main Policy[K, V]) (*TinyLFUPolicy[K, V], error) {
+	if err := validateRatio("window", windowRatio); err != nil {
+		return nil, err
+	}
+	if main == nil {
+		main = NewLRUPolicy[K, V]()
+	}
+
+	return &TinyLFUPolicy[K, V]{
+		main:        main,
+		sketch:      newCountMinSketch(entries),
+		seed:        maphash.MakeSeed(),
+		windowRatio: windowRatio,
+		window:      list.New(),
+		elements:    make(map[*LRUHandle[K, V]]*list.Element),
+		candidates:  list.New(),
+		candidate:   make(map[*LRUHandle[K, V]]*list.Element),
+	}, nil
+}
+
+// 开启W-TinyLFU准入策略：包装cache当前的淘汰策略(未设置时为LRU)作为main
+// windowRatio为window段占比 取值(0, 1)
+// 若已开启分段模式 则main即为probation/protected段(即Caffeine的W-TinyLFU)
+// windowRatio无效时返回ErrInvalidRatio 不改变当前的策略
+func (p *LRUCache[K, V]) EnableTinyLFU(windowRatio float64) error {
+	p.mu.Lock() // 检查当前的策略与替换在同一个临界区内：并发的SetSegmented/EnableTinyLFU不会丢失
+	defer p.mu.Unlock()
+
+	policy, err := NewTinyLFUPolicy[K, V](windowRatio, int(min(p.capacity, maxSketchWidth)), nil)
+	if err != nil {
+		return err
+	}
+	main := p.policy
+	if t, ok := main.(*TinyLFUPolicy[K, V]); ok { // 已开启：重新包装其main
+		main = t.main
+	}
+	if main == nil {
+		p.setPolicy(policy)
+		return nil
+	}
+
+	// main已包含cache中的entry：直接复用
+	policy.main = main
+	for element := p.list.Front(); element != nil; element = element.Next() {
+		policy.size += element.Value.(*LRUHandle[K, V]).size
+	}
+	p.storePolicy(policy)
+	p.policyCapacity()
+	return nil
+}
+
+// 准入策略的统计：被准入及被拒绝的候选者个数
+func (p *LRUCache[K, V]) AdmissionStats() (admitted, rejected int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if policy, ok := p.policy.(*TinyLFUPolicy[K, V]); ok {
+		return policy.admitted, policy.rejected
+	}
+	return
+}
+
+// key的hash
+func (t *TinyLFUPolicy[K, V]) keyHash(key K) uint64 {
+	return maphash.Comparable(t.seed, key)
+}
+
+// 新的entry进入window段 window段溢出的entry进入main成为候选者
+// 上一次插入产生的候选者若仍未被比较(cache未满) 则直接被准入
+func (t *TinyLFUPolicy[K, V]) Insert(h *LRUHandle[K, V]) {
+	t.candidates.Init()
+	clear(t.candidate)
+
+	t.sketch.increment(t.keyHash(h.key))
+	t.elements[h] = t.window.PushFront(h)
+	t.windowSize += h.size
+	t.size += h.size
+
+	limit := max(int64(float64(t.size)*t.windowRatio), 1)
+	for t.windowSize > limit && t.window.Len() > 1 {
+		c := t.window.Remove(t.window.Back()).(*LRUHandle[K, V])
+		delete(t.elements, c)
+		t.windowSize -= c.size
+
+		t.main.Insert(c)
+		t.candidate[c] = t.candidates.PushFront(c)
+	}
+}
+
+// cache的capacity改变：main的容量为capacity去掉window段
+func (t *TinyLFUPolicy[K, V]) SetCapacity(capacity int64) {
+	if c, ok := t.main.(CapacityPolicy[K, V]); ok {
+		c.SetCapacity(capacity - int64(float64(capacity)*t.windowRatio))
+	}
+}
+
+// key未命中：记录频率
+func (t *TinyLFUPolicy[K, V]) RecordMiss(key K) {
+	t.sketch.increment(t.keyHash(key))
+}
+
+// entry被访问：记录频率 window段中的entry移动到表头
+func (t *TinyLFUPolicy[K, V]) Access(h *LRUHandle[K, V]) {
+	t.sketch.increment(t.keyHash(h.key))
+	if element := t.elements[h]; element != nil {
+		t.window.MoveToFront(element)
+		return
+	}
+	t.main.Access(h)
+}
+
+// entry被移出cache
+func (t *TinyLFUPolicy[K, V]) Remove(h *LRUHandle[K, V]) {
+	t.size -= h.size
+	if element := t.elements[h]; element != nil {
+		t.window.Remove(element)
+		delete(t.elements, h)
+		t.windowSize -= h.size
+		return
+	}
+	if element := t.candidate[h]; element != nil {
+		t.candidates.Remove(element)
+		delete(t.candidate, h)
+	}
+	t.main.Remove(h)
+}
+
+// 选择淘汰的entry
+// 存在候选者时：最旧的候选者与main的victim比较频率 返回频率低的一方
+// 否则返回main的victim(main为空时返回window段最旧的entry)
+func (t *TinyLFUPolicy[K, V]) Victim() *LRUHandle[K, V] {
+	victim := t.main.Victim()
+	for back := t.candidates.Back(); back != nil; back = t.candidates.Back() {
+		c := back.Value.(*LRUHandle[K, V])
+		t.candidates.Remove(back)
+		delete(t.candidate, c)
+		if c == victim { // 候选者本身就是main的victim
+			continue
+		}
+
+		if t.sketch.estimate(t.keyHash(c.key)) > t.sketch.estimate(t.keyHash(victim.key)) {
+			t.admitted++
+			return victim
+		}
+		t.rejected++
+		return c
+	}
+
+	if victim != nil {
+		return victim
+	}
+	if back := t.window.Back(); back != nil {
+		return back.Value.(*LRUHandle[K, V])
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+)
+
+// 并发的SetSegmented与EnableTinyLFU：无论先后 最终都应为分段模式
+func TestEnableTinyLFUConcurrentWithSetSegmented(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		c, err := NewLRUCache[int, int](100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k := 0; k < 50; k++ {
+			c.Set(k, k, 1)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := c.SetSegmented(DefaultProtectedRatio); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := c.EnableTinyLFU(DefaultWindowRatio); err != nil {
+				t.Error(err)
+			}
+		}()
+		wg.Wait()
+
+		if !c.Segmented() {
+			t.Fatalf("policy = %T after concurrent SetSegmented and EnableTinyLFU, want segmented", c.Policy())
+		}
+		for k := 50; k < 200; k++ { // 淘汰时策略中的entry与cache一致
+			c.Set(k, k, 1)
+		}
+		if c.Length() != 100 {
+			t.Fatalf("Length = %d, want 100", c.Length())
+		}
+		c.Close()
+	}
+}
+
+// 未命中的Lookup计入频率：多次未命中的key插入后应被准入 只出现一次的key被拒绝
+func TestTinyLFUAdmitsKeysWithFrequentMisses(t *testing.T) {
+	c, err := NewLRUCache[int, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.EnableTinyLFU(DefaultWindowRatio); err != nil {
+		t.Fatal(err)
+	}
+
+	for k := 0; k < 100; k++ {
+		c.Set(k, k, 1)
+	}
+	const hot = 1000
+	for i := 0; i < 10; i++ {
+		if _, ok := c.Get(hot); ok {
+			t.Fatal("hot key present before insert")
+		}
+	}
+
+	c.Set(hot, hot, 1)
+	for k := 2000; k < 2010; k++ { // hot离开window段成为候选者 之后的冷key均被拒绝
+		c.Set(k, k, 1)
+	}
+	if _, ok := c.Entry(hot); !ok {
+		t.Error("key with frequent misses was not admitted")
+	}
+	admitted, rejected := c.AdmissionStats()
+	if admitted == 0 || rejected == 0 {
+		t.Errorf("AdmissionStats = %d, %d, want both > 0", admitted, rejected)
+	}
+	if c.Length() != 100 {
+		t.Errorf("Length = %d, want 100", c.Length())
+	}
+}
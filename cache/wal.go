@@ -0,0 +1,610 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WAL的fsync策略
+type WALSyncPolicy int
+
+const (
+	WALSyncInterval WALSyncPolicy = iota // 每隔SyncInterval fsync一次(默认)：宕机最多丢失一个周期内的写入
+	WALSyncAlways                        // 每条记录都fsync：最安全 也最慢
+	WALSyncNever                         // 从不主动fsync：由操作系统决定何时落盘
+)
+
+// WAL的默认参数
+const (
+	DefaultWALSyncInterval    = time.Second
+	DefaultWALSegmentSize     = 64 << 20
+	DefaultWALCompactInterval = 10 * time.Minute
+)
+
+// EnableWAL的参数 零值字段使用默认值
+type WALOptions struct {
+	Dir             string        // 日志目录 不存在时创建
+	Sync            WALSyncPolicy // fsync策略
+	SyncInterval    time.Duration // WALSyncInterval时的fsync周期
+	SegmentSize     int64         // 单个日志文件超过该大小后切换到新的文件
+	CompactInterval time.Duration // 后台压缩的周期 < 0表示不进行后台压缩(仍可手动调用Compact)
+}
+
+// 日志记录的类型
+const (
+	walPut   byte = 1 // 插入：body同快照记录
+	walDel   byte = 2 // 删除：Erase、Take、淘汰、过期
+	walClear byte = 3 // 清空：Clear以及压缩后的日志文件开头
+)
+
+// 日志文件：<dir>/<seq>.wal seq为16位十六进制 按seq顺序回放
+// 记录格式同快照记录：length(4) body(length) crc32(4) body的第一个字节为记录类型
+const (
+	walSuffix        = ".wal"
+	walCompactSuffix = ".compact" // 压缩过程中的临时文件
+)
+
+// cache的WAL 所有字段由cache的锁保护
+type walLog[K comparable, V any] struct {
+	opts     WALOptions
+	keyCodec Codec[K]
+	codec    Codec[V]
+
+	seq     uint64 // 当前写入的日志文件
+	file    *os.File
+	w       *bufio.Writer
+	written int64 // 当前日志文件的大小
+
+	dirty      bool  // 有未fsync的记录
+	appended   bool  // 上次压缩后有新的记录
+	compacting bool  // 正在压缩
+	err        error // 第一次写入失败的错误：此后不再写入
+
+	buf  []byte
+	stop chan struct{}
+}
+
+// 开启WAL：回放opts.Dir中已有的日志重建cache 此后Insert、Erase、Take、淘汰、过期、Clear都会追加到日志
+// 回放完成后立即压缩一次 并按opts.CompactInterval在后台定期压缩
+// 回放不会恢复deleter 也不记录访问：回放后entry按照插入顺序排列
+// 回放只插入日志中最终存在的entry：cache中已有的entry不会被日志中的删除、清空移除 也不会因此回调RemovalListener
+// 最后一个日志文件末尾不完整的记录(写入时宕机)会被截断 其余的损坏返回ErrCorruptWAL 且不修改cache
+// 注：value的序列化方式同快照(SetValueCodec) 应在EnableWAL之前设置
+func (p *LRUCache[K, V]) EnableWAL(opts WALOptions) error {
+	if opts.Dir == "" {
+		return errors.New("cache: WAL directory not set")
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = DefaultWALSyncInterval
+	}
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultWALSegmentSize
+	}
+	if opts.CompactInterval == 0 {
+		opts.CompactInterval = DefaultWALCompactInterval
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrCacheClosed
+	}
+	if p.wal != nil {
+		p.mu.Unlock()
+		return errors.New("cache: WAL already enabled")
+	}
+
+	w := &walLog[K, V]{
+		opts:     opts,
+		keyCodec: GobCodec[K](),
+		codec:    p.valueCodec(),
+		stop:     make(chan struct{}),
+	}
+	seqs, err := walSegments(opts.Dir)
+	if err == nil {
+		err = p.replay(w, seqs)
+	}
+	if err == nil {
+		if len(seqs) > 0 {
+			w.seq = seqs[len(seqs)-1]
+		}
+		err = w.open(w.seq + 1)
+	}
+	if err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	p.wal = w
+	p.mu.Unlock()
+
+	if err := p.Compact(); err != nil {
+		return err
+	}
+	p.startWAL(w)
+	return nil
+}
+
+// 日志写入失败的错误 写入失败后不再写入日志
+func (p *LRUCache[K, V]) WALErr() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.wal == nil {
+		return nil
+	}
+	return p.wal.err
+}
+
+// 将日志刷到磁盘
+func (p *LRUCache[K, V]) SyncWAL() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.wal == nil {
+		return nil
+	}
+	p.wal.sync()
+	return p.wal.err
+}
+
+// 压缩日志：将cache中现有的entry写入新的日志文件 并删除旧的日志文件
+// 只在切换日志文件和收集entry时持有锁 写入在锁外进行
+func (p *LRUCache[K, V]) Compact() error {
+	p.mu.Lock()
+	w := p.wal
+	if w == nil || w.compacting {
+		p.mu.Unlock()
+		return nil
+	}
+	if w.err != nil {
+		p.mu.Unlock()
+		return w.err
+	}
+
+	// 切换日志文件：此后的记录写入新的文件 被压缩的文件为seq及之前的文件
+	seq := w.seq
+	w.rotate()
+	if w.err != nil {
+		p.mu.Unlock()
+		return w.err
+	}
+	handles := make([]*LRUHandle[K, V], 0, len(p.table))
+	for element := p.list.Back(); element != nil; element = element.Prev() {
+		h := element.Value.(*LRUHandle[K, V])
+		p.addref(h)
+		handles = append(handles, h)
+	}
+	w.compacting = true
+	w.appended = false
+	p.mu.Unlock()
+
+	err := w.compact(seq, handles)
+	p.releaseHandles(handles)
+
+	p.mu.Lock()
+	w.compacting = false
+	p.mu.Unlock()
+	return err
+}
+
+// 后台fsync以及压缩
+func (p *LRUCache[K, V]) startWAL(w *walLog[K, V]) {
+	if w.opts.Sync != WALSyncInterval && w.opts.CompactInterval <= 0 {
+		return
+	}
+
+	go func() {
+		var syncC, compactC <-chan time.Time
+		if w.opts.Sync == WALSyncInterval {
+			ticker := time.NewTicker(w.opts.SyncInterval)
+			defer ticker.Stop()
+			syncC = ticker.C
+		}
+		if w.opts.CompactInterval > 0 {
+			ticker := time.NewTicker(w.opts.CompactInterval)
+			defer ticker.Stop()
+			compactC = ticker.C
+		}
+
+		for {
+			select {
+			case <-syncC:
+				p.mu.Lock()
+				if p.wal == w {
+					w.sync()
+				}
+				p.mu.Unlock()
+			case <-compactC:
+				p.mu.Lock()
+				appended := p.wal == w && w.appended
+				p.mu.Unlock()
+				if appended {
+					p.Compact()
+				}
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// 关闭WAL：刷盘并关闭日志文件 必须持有锁
+// 注：cache的Close不会写入删除记录 重新开启WAL时可恢复全部entry
+func (p *_LRUCache[K, V]) closeWAL() {
+	if p.wal == nil {
+		return
+	}
+	close(p.wal.stop)
+	p.wal.sync()
+	if p.wal.file != nil {
+		p.wal.file.Close()
+	}
+	p.wal = nil
+}
+
+// 记录插入 必须持有锁
+func (p *_LRUCache[K, V]) walPut(h *LRUHandle[K, V]) {
+	if p.wal == nil {
+		return
+	}
+	w := p.wal
+	body, err := encodeRecord(append(w.buf[:0], walPut), h, w.keyCodec, w.codec)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	w.append(body)
+}
+
+// 记录清空 必须持有锁
+func (p *_LRUCache[K, V]) walReset() {
+	if p.wal != nil {
+		p.wal.append(append(p.wal.buf[:0], walClear))
+	}
+}
+
+// 记录移除 必须持有锁
+// 替换由随后的插入记录覆盖 cache的Close不记录
+func (p *_LRUCache[K, V]) walRemove(h *LRUHandle[K, V], reason RemovalReason) {
+	if p.wal == nil {
+		return
+	}
+	w := p.wal
+	switch reason {
+	case RemovalReplaced, RemovalClosed, RemovalCleared: // Clear只记录一条清空记录 见walReset
+		return
+	}
+
+	key, err := w.keyCodec.Marshal(h.key)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	body := append(w.buf[:0], walDel)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(key)))
+	w.append(append(body, key...))
+}
+
+// 回放日志 必须持有锁
+// 先读取全部日志得到最终的entry 再插入cache：日志中的删除、清空只作用于日志中的entry
+// 不会移除开启WAL之前已在cache中的entry 也不会为其回调RemovalListener、deleter(同key的entry被替换除外)
+// 日志损坏(ErrCorruptWAL)时不修改cache
+func (p *LRUCache[K, V]) replay(w *walLog[K, V], seqs []uint64) error {
+	var st walState[K, V]
+	for i, seq := range seqs {
+		path := walPath(w.opts.Dir, seq)
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		good, err := replaySegment(w, bufio.NewReader(f), &st)
+		f.Close()
+		if err == nil {
+			continue
+		}
+		var torn tornRecordError
+		if i < len(seqs)-1 || !errors.As(err, &torn) {
+			return fmt.Errorf("%w: %s at offset %d: %v", ErrCorruptWAL, path, good, err)
+		}
+		// 最后一个文件末尾不完整的记录：写入时宕机 截断
+		if err := os.Truncate(path, good); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for i, rec := range st.records {
+		if j, ok := st.index[rec.key]; ok && j == i { // 未被之后的记录替换或删除
+			p.insertRecord(rec, now)
+		}
+	}
+	return nil
+}
+
+// 回放得到的entry
+type walState[K comparable, V any] struct {
+	records []snapshotRecord[K, V] // 按插入顺序
+	index   map[K]int              // key -> 最新的记录在records中的下标
+}
+
+func (st *walState[K, V]) put(rec snapshotRecord[K, V]) {
+	if st.index == nil {
+		st.index = make(map[K]int)
+	}
+	st.index[rec.key] = len(st.records)
+	st.records = append(st.records, rec)
+}
+
+func (st *walState[K, V]) del(key K) {
+	delete(st.index, key)
+}
+
+func (st *walState[K, V]) clear() {
+	st.records, st.index = nil, nil
+}
+
+// 最后一条记录不完整：写入时宕机 可以截断
+type tornRecordError struct {
+	err error
+}
+
+func (e tornRecordError) Error() string {
+	return "incomplete record: " + e.err.Error()
+}
+
+// 读取一个日志文件 返回最后一条完整记录的结束位置
+// 记录不完整(文件在记录中间结束 或文件最后一条记录校验失败)时返回tornRecordError 其余的错误均为损坏
+func replaySegment[K comparable, V any](w *walLog[K, V], r *bufio.Reader, st *walState[K, V]) (good int64, err error) {
+	// 读取时遇到文件结尾：记录不完整
+	readFull := func(b []byte) error {
+		if _, err := io.ReadFull(r, b); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return tornRecordError{io.ErrUnexpectedEOF}
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	var buf [4]byte
+	for {
+		if _, err = r.Peek(1); err == io.EOF {
+			return good, nil
+		}
+		if err = readFull(buf[:]); err != nil {
+			return good, err
+		}
+		n := binary.BigEndian.Uint32(buf[:])
+		if n == 0 || n > maxSnapshotRecord {
+			return good, fmt.Errorf("invalid record length %d", n)
+		}
+		body := make([]byte, n)
+		if err = readFull(body); err != nil {
+			return good, err
+		}
+		if err = readFull(buf[:]); err != nil {
+			return good, err
+		}
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(buf[:]) {
+			if _, err := r.Peek(1); err == io.EOF { // 最后一条记录：写入时宕机
+				return good, tornRecordError{errors.New("checksum mismatch")}
+			}
+			return good, errors.New("checksum mismatch")
+		}
+
+		switch body[0] {
+		case walPut:
+			rec, err := decodeRecord(body[1:], w.keyCodec, w.codec)
+			if err != nil {
+				return good, err
+			}
+			st.put(rec)
+		case walDel:
+			if len(body) < 5 || int(binary.BigEndian.Uint32(body[1:])) != len(body)-5 {
+				return good, errors.New("malformed delete record")
+			}
+			var key K
+			if err = w.keyCodec.Unmarshal(body[5:], &key); err != nil {
+				return good, err
+			}
+			st.del(key)
+		case walClear:
+			st.clear()
+		default:
+			return good, fmt.Errorf("unknown record type %d", body[0])
+		}
+		good += int64(n) + 8
+	}
+}
+
+// ========================================walLog=====================================
+
+func walPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%016x%s", seq, walSuffix))
+}
+
+// 目录中所有日志文件的seq 从小到大
+func walSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, walCompactSuffix) { // 上次压缩未完成
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, walSuffix) {
+			continue
+		}
+		if seq, err := strconv.ParseUint(strings.TrimSuffix(name, walSuffix), 16, 64); err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// 打开新的日志文件
+func (w *walLog[K, V]) open(seq uint64) error {
+	f, err := os.OpenFile(walPath(w.opts.Dir, seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.seq, w.file, w.written = seq, f, 0
+	if w.w == nil {
+		w.w = bufio.NewWriter(f)
+	} else {
+		w.w.Reset(f)
+	}
+	return nil
+}
+
+// 切换到新的日志文件
+func (w *walLog[K, V]) rotate() {
+	w.sync()
+	if w.err != nil {
+		return
+	}
+	if err := w.file.Close(); err != nil {
+		w.fail(err)
+		return
+	}
+	w.file = nil
+	if err := w.open(w.seq + 1); err != nil {
+		w.fail(err)
+	}
+}
+
+// 追加一条记录
+func (w *walLog[K, V]) append(body []byte) {
+	w.buf = body[:0]
+	if w.err != nil {
+		return
+	}
+
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(len(body)))
+	w.w.Write(buf[:])
+	w.w.Write(body)
+	binary.BigEndian.PutUint32(buf[:], crc32.ChecksumIEEE(body))
+	if _, err := w.w.Write(buf[:]); err != nil {
+		w.fail(err)
+		return
+	}
+	w.written += int64(len(body)) + 8
+	w.dirty = true
+	w.appended = true
+
+	if w.opts.Sync == WALSyncAlways {
+		w.sync()
+	}
+	if w.written >= w.opts.SegmentSize {
+		w.rotate()
+	}
+}
+
+// 刷盘
+func (w *walLog[K, V]) sync() {
+	if w.err != nil || w.file == nil {
+		return
+	}
+	if err := w.w.Flush(); err != nil {
+		w.fail(err)
+		return
+	}
+	if !w.dirty || w.opts.Sync == WALSyncNever {
+		return
+	}
+	if err := w.file.Sync(); err != nil {
+		w.fail(err)
+		return
+	}
+	w.dirty = false
+}
+
+func (w *walLog[K, V]) fail(err error) {
+	if w.err == nil {
+		w.err = fmt.Errorf("cache: WAL: %w", err)
+	}
+}
+
+// 将handles写入临时文件 完成后替换seq对应的日志文件 并删除seq之前的日志文件
+// 新文件以清空记录开头：即使在删除旧文件前宕机 回放结果仍然正确
+// 注：不持有cache的锁 只访问不变的字段
+func (w *walLog[K, V]) compact(seq uint64, handles []*LRUHandle[K, V]) error {
+	dir := w.opts.Dir
+	tmp := strings.TrimSuffix(walPath(dir, seq), walSuffix) + walCompactSuffix
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	bw := bufio.NewWriter(f)
+	write := func(body []byte) {
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], uint32(len(body)))
+		bw.Write(buf[:])
+		bw.Write(body)
+		binary.BigEndian.PutUint32(buf[:], crc32.ChecksumIEEE(body))
+		bw.Write(buf[:])
+	}
+
+	write([]byte{walClear})
+	var body []byte
+	for _, h := range handles {
+		if body, err = encodeRecord(append(body[:0], walPut), h, w.keyCodec, w.codec); err != nil {
+			f.Close()
+			return err
+		}
+		write(body)
+	}
+	if err = bw.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp, walPath(dir, seq)); err != nil {
+		return err
+	}
+	syncDir(dir)
+
+	seqs, err := walSegments(dir)
+	if err != nil {
+		return err
+	}
+	for _, s := range seqs {
+		if s < seq {
+			os.Remove(walPath(dir, s))
+		}
+	}
+	return nil
+}
+
+// 目录fsync：保证rename落盘
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
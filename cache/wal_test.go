@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// 开启WAL的cache
+func newWALCache(t *testing.T, dir string) *LRUCache[string, int] {
+	t.Helper()
+	c, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EnableWAL(WALOptions{Dir: dir, Sync: WALSyncAlways, CompactInterval: -1}); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// 写入a=1 b=2 c=3 删除b 返回最后一个日志文件
+func writeWAL(t *testing.T, dir string) string {
+	t.Helper()
+	c := newWALCache(t, dir)
+	c.Set("a", 1, 1)
+	c.Set("b", 2, 1)
+	c.Set("c", 3, 1)
+	c.Erase("b")
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	seqs, err := walSegments(dir)
+	if err != nil || len(seqs) == 0 {
+		t.Fatalf("walSegments = %v, %v", seqs, err)
+	}
+	return walPath(dir, seqs[len(seqs)-1])
+}
+
+func checkEntries(t *testing.T, c *LRUCache[string, int], want map[string]int) {
+	t.Helper()
+	if c.Length() != int64(len(want)) {
+		t.Errorf("Length = %d, want %d (keys %v)", c.Length(), len(want), c.Keys())
+	}
+	for k, v := range want {
+		if got, ok := c.Get(k); !ok || got != v {
+			t.Errorf("Get(%q) = %d, %v, want %d, true", k, got, ok, v)
+		}
+	}
+}
+
+func TestWALReplay(t *testing.T) {
+	dir := t.TempDir()
+	writeWAL(t, dir)
+
+	c := newWALCache(t, dir)
+	defer c.Close()
+	checkEntries(t, c, map[string]int{"a": 1, "c": 3})
+}
+
+// 最后一个文件末尾不完整的记录被截断 之前的记录正常回放
+func TestWALReplayTruncatesTornTail(t *testing.T) {
+	for name, tear := range map[string]func(data []byte) []byte{
+		"partial length": func(data []byte) []byte { return append(data, 0, 0) },
+		"partial body":   func(data []byte) []byte { return append(data, 0, 0, 0, 100, walPut, 1, 2) },
+		"bad checksum": func(data []byte) []byte { // 最后一条记录(删除b)写入了一半
+			data[len(data)-5] ^= 0xff
+			return data
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeWAL(t, dir)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			good := len(data)
+			torn := tear(append([]byte(nil), data...))
+			if name == "bad checksum" {
+				good = lastRecordStart(t, data)
+			}
+			if err := os.WriteFile(path, torn, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := NewLRUCache[string, int](100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			// 截断发生在回放时：不压缩 以便检查文件
+			if err := c.EnableWAL(WALOptions{Dir: dir, Sync: WALSyncAlways, CompactInterval: -1}); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]int{"a": 1, "c": 3}
+			if name == "bad checksum" { // 删除b的记录被丢弃
+				want["b"] = 2
+			}
+			checkEntries(t, c, want)
+			if info, err := os.Stat(path); err == nil && info.Size() != int64(good) {
+				t.Errorf("segment size after replay = %d, want %d", info.Size(), good)
+			}
+		})
+	}
+}
+
+// 文件中间的损坏返回ErrCorruptWAL 不截断 不修改cache
+func TestWALReplayCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	path := writeWAL(t, dir)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[8] ^= 0xff // 第一条记录的body
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Set("x", 9, 1)
+	if err := c.EnableWAL(WALOptions{Dir: dir}); !errors.Is(err, ErrCorruptWAL) {
+		t.Fatalf("EnableWAL = %v, want ErrCorruptWAL", err)
+	}
+	checkEntries(t, c, map[string]int{"x": 9})
+	if info, err := os.Stat(path); err != nil || info.Size() != int64(len(data)) {
+		t.Errorf("corrupt segment was modified: %v, %v", info.Size(), err)
+	}
+}
+
+// 回放中的删除、清空只作用于日志中的entry：不移除开启WAL之前的entry 也不回调监听及deleter
+func TestWALReplayDoesNotNotify(t *testing.T) {
+	dir := t.TempDir()
+	c := newWALCache(t, dir)
+	c.Set("x", 1, 1)
+	c.Set("y", 2, 1)
+	c.Clear()
+	c.Set("z", 3, 1)
+	c.Erase("z")
+	c.Set("w", 4, 1)
+	c.Close()
+
+	c, err := NewLRUCache[string, int](100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var removed []RemovalReason
+	c.SetRemovalListener(func(key string, value int, size int64, reason RemovalReason) {
+		removed = append(removed, reason)
+	})
+	deleted := false
+	c.Set("x", 10, 1, func(string, int) { deleted = true })
+	c.Set("z", 30, 1)
+
+	if err := c.EnableWAL(WALOptions{Dir: dir, CompactInterval: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 0 || deleted {
+		t.Errorf("replay notified removals %v (deleter called: %v)", removed, deleted)
+	}
+	checkEntries(t, c, map[string]int{"x": 10, "z": 30, "w": 4})
+}
+
+// data中最后一条记录的起始位置
+func lastRecordStart(t *testing.T, data []byte) int {
+	t.Helper()
+	start := 0
+	for pos := 0; pos < len(data); {
+		n := int(data[pos])<<24 | int(data[pos+1])<<16 | int(data[pos+2])<<8 | int(data[pos+3])
+		start = pos
+		pos += n + 8
+	}
+	return start
+}
@@ -1,6 +1,8 @@
 package cache_go
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"time"
 	"log"
@@ -20,6 +22,8 @@ type CacheTable struct {
 	logger *log.Logger            // table操作记录
 
 	loadData func(key interface{}, args ...interface{}) *CacheItem  //
+	loadDataContext func(ctx context.Context, key interface{}, args ...interface{}) (*CacheItem, error)
+	loading map[interface{}]*loadCall  // 正在执行的ValueContext加载
 	addedItem	func(item *CacheItem)
 	aboutToDeleteItem func(item *CacheItem)
 }
@@ -49,6 +53,14 @@ func (table *CacheTable) SetDataLoader(f func(interface{}, ...interface{}) *Cach
 }
 
 
+// 设置可感知context的loader：供ValueContext使用
+func (table *CacheTable) SetDataLoaderContext(f func(context.Context, interface{}, ...interface{}) (*CacheItem, error)) {
+	table.Lock()
+	defer table.Unlock()
+	table.loadDataContext = f
+}
+
+
 func (table *CacheTable) SetAddedItemCallback(f func(*CacheItem)) {
 	table.Lock()
 	defer table.Unlock()
@@ -190,6 +202,15 @@ func (table *CacheTable) Exists(key interface{}) bool {
 	return ok
 }
 
+// 获取指定key的item 不更新access count和timestamp(不会延长item的有效期) 也不会通过loadData加载
+func (table *CacheTable) Peek(key interface{}) (*CacheItem, bool) {
+	table.RLock()
+	defer table.RUnlock()
+	r, ok := table.items[key]
+
+	return r, ok
+}
+
 // cache中不存在 则进行添加
 func (table *CacheTable) NotFoundAdd(key interface{}, lifeSpan time.Duration, data interface{}) bool {
 	table.Lock()
@@ -235,6 +256,96 @@ func (table *CacheTable) Value(key interface{}, args ...interface{}) (*CacheItem
 }
 
 
+// 同Value 不过加载数据时可通过ctx取消或设置超时
+// 优先使用SetDataLoaderContext设置的loader，否则使用SetDataLoader设置的loader
+// 同一key的并发调用只会执行一次loader：其余调用等待并共享其结果(item或err) loader出错时不会添加到cache中
+// ctx结束时返回*LoadCanceledError；只有当所有调用方都已离开 才会取消loader的ctx 此后loader的结果不会被添加到cache中
+func (table *CacheTable) ValueContext(ctx context.Context, key interface{}, args ...interface{}) (*CacheItem, error) {
+	table.Lock()
+	r, ok := table.items[key]
+	if ok {
+		table.Unlock()
+		r.KeepAlive()
+		return r, nil
+	}
+
+	loadDataContext := table.loadDataContext
+	if loadDataContext == nil {
+		loadData := table.loadData
+		if loadData == nil {
+			table.Unlock()
+			return nil, ErrKeyNotFound
+		}
+		loadDataContext = func(_ context.Context, key interface{}, args ...interface{}) (*CacheItem, error) {
+			return loadData(key, args...), nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		table.Unlock()
+		return nil, &LoadCanceledError{Key: key, Err: err}
+	}
+
+	c, ok := table.loading[key]
+	if ok {  // 已有调用正在加载该key 等待其结果
+		c.waiters++
+	} else {
+		c = &loadCall{done: make(chan struct{}), waiters: 1}
+		c.ctx, c.cancel = context.WithCancel(context.WithoutCancel(ctx))  // 继承ctx中的value 但不继承其取消
+		if table.loading == nil {
+			table.loading = make(map[interface{}]*loadCall)
+		}
+		table.loading[key] = c
+		go table.load(key, c, loadDataContext, args)  // 在独立的goroutine中执行 以便调用方可随时离开
+	}
+	table.Unlock()
+
+	select {
+	case <-c.done:
+		return c.item, c.err
+	case <-ctx.Done():
+		table.Lock()
+		c.waiters--
+		if c.waiters == 0 && table.loading[key] == c {  // 最后一个等待者离开：放弃该次加载
+			delete(table.loading, key)
+			c.cancel()
+		}
+		table.Unlock()
+		return nil, &LoadCanceledError{Key: key, Err: ctx.Err()}
+	}
+}
+
+// 执行loader 并在该次加载未被放弃时将结果添加到cache 然后唤醒所有等待者
+func (table *CacheTable) load(key interface{}, c *loadCall, loadDataContext func(context.Context, interface{}, ...interface{}) (*CacheItem, error), args []interface{}) {
+	var item *CacheItem
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {  // loader panic：转换为error返回给所有等待者
+				err = fmt.Errorf("cache: loader of %v panicked: %v", key, r)
+			}
+		}()
+		item, err = loadDataContext(c.ctx, key, args...)
+	}()
+	if err == nil && item == nil {
+		err = ErrKeyNotFoundOrLoadable
+	}
+	defer c.cancel()
+
+	table.Lock()
+	if table.loading[key] != c {  // 已被所有调用方放弃
+		table.Unlock()
+		return
+	}
+	delete(table.loading, key)
+	c.item, c.err = item, err
+	if err != nil {
+		table.Unlock()
+	} else {
+		table.addInternal(NewCacheItem(key, item.lifeSpan, item.data))  // 释放table的lock
+	}
+	close(c.done)
+}
+
 // 清空cache table中的items
 func (table *CacheTable) Flush() {
 	table.Lock()
@@ -250,6 +361,18 @@ func (table *CacheTable) Flush() {
 	}
 }
 
+// 正在执行中的ValueContext加载
+type loadCall struct {
+	done chan struct{}  // 加载结束后关闭
+
+	item *CacheItem
+	err  error
+
+	waiters int  // 仍在等待结果的调用方数
+	ctx     context.Context  // loader使用的context：所有等待者都离开后被取消
+	cancel  context.CancelFunc
+}
+
 //
 type CacheItemPair struct {
 	Key         interface{}
@@ -299,5 +422,5 @@ func (table *CacheTable) log(v ...interface{}) {
 		return
 	}
 
-	table.logger.Println(v)
+	table.logger.Println(v...)
 }
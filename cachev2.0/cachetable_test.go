@@ -0,0 +1,177 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// table注册在全局的cache中：-count > 1时清除上一次运行的items
+func testTable(t *testing.T) *CacheTable {
+	table := Cache(t.Name())
+	table.Flush()
+	t.Cleanup(table.Flush)
+	return table
+}
+
+// 等待key对应的加载有n个等待者
+func waitWaiters(t *testing.T, table *CacheTable, key interface{}, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		table.RLock()
+		c, ok := table.loading[key]
+		got := 0
+		if ok {
+			got = c.waiters
+		}
+		table.RUnlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("waiters of %v = %d, want %d", key, got, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestValueContextCollapsesConcurrentLoads(t *testing.T) {
+	table := testTable(t)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	table.SetDataLoaderContext(func(ctx context.Context, key interface{}, args ...interface{}) (*CacheItem, error) {
+		calls.Add(1)
+		<-release
+		return NewCacheItem(key, 0, "v"), nil
+	})
+
+	const n = 32
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			item, err := table.ValueContext(context.Background(), "k")
+			if err == nil && item.Data() != "v" {
+				err = errors.New("wrong value")
+			}
+			errs <- err
+		}()
+	}
+	waitWaiters(t, table, "k", n)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("loader called %d times, want 1", got)
+	}
+	if !table.Exists("k") {
+		t.Error("loaded item was not added")
+	}
+}
+
+func TestValueContextCancelDoesNotAffectOtherWaiters(t *testing.T) {
+	table := testTable(t)
+	release := make(chan struct{})
+	var loaderErr error
+	table.SetDataLoaderContext(func(ctx context.Context, key interface{}, args ...interface{}) (*CacheItem, error) {
+		<-release
+		loaderErr = ctx.Err()
+		return NewCacheItem(key, 0, "v"), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := table.ValueContext(ctx, "k")
+		canceled <- err
+	}()
+	waitWaiters(t, table, "k", 1)
+	other := make(chan error, 1)
+	go func() {
+		item, err := table.ValueContext(context.Background(), "k")
+		if err == nil && item.Data() != "v" {
+			err = errors.New("wrong value")
+		}
+		other <- err
+	}()
+	waitWaiters(t, table, "k", 2)
+
+	cancel()
+	err := <-canceled
+	var lce *LoadCanceledError
+	if !errors.As(err, &lce) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled caller err = %v, want *LoadCanceledError wrapping context.Canceled", err)
+	}
+	waitWaiters(t, table, "k", 1)
+
+	close(release)
+	if err := <-other; err != nil {
+		t.Fatalf("other waiter err = %v", err)
+	}
+	if loaderErr != nil {
+		t.Fatalf("loader ctx canceled while a waiter remained: %v", loaderErr)
+	}
+	if !table.Exists("k") {
+		t.Error("loaded item was not added")
+	}
+}
+
+func TestValueContextAbandonedWhenAllWaitersLeave(t *testing.T) {
+	table := testTable(t)
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	table.SetDataLoaderContext(func(ctx context.Context, key interface{}, args ...interface{}) (*CacheItem, error) {
+		defer close(finished)
+		close(started)
+		<-ctx.Done() // 所有调用方离开后被取消
+		return NewCacheItem(key, 0, "late"), nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := table.ValueContext(ctx, "k"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	<-started
+	<-finished
+	if table.Exists("k") {
+		t.Fatal("result of abandoned load was added")
+	}
+}
+
+func TestValueContextErrorNotCached(t *testing.T) {
+	table := testTable(t)
+	errBackend := errors.New("backend down")
+	var calls atomic.Int32
+	table.SetDataLoaderContext(func(ctx context.Context, key interface{}, args ...interface{}) (*CacheItem, error) {
+		if calls.Add(1) == 1 {
+			return nil, errBackend
+		}
+		return NewCacheItem(key, 0, "v"), nil
+	})
+
+	if _, err := table.ValueContext(context.Background(), "k"); !errors.Is(err, errBackend) {
+		t.Fatalf("err = %v, want %v", err, errBackend)
+	}
+	if table.Exists("k") {
+		t.Fatal("failed load was added")
+	}
+	if item, err := table.ValueContext(context.Background(), "k"); err != nil || item.Data() != "v" {
+		t.Fatalf("ValueContext after error = %v, %v", item, err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("loader called %d times, want 2", got)
+	}
+}
@@ -0,0 +1,29 @@
+package cache_go
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// cache中不存在对应的key
+	ErrKeyNotFound = errors.New("Key not found in cache")
+
+	// cache中不存在对应的key 且无法通过loadData加载
+	ErrKeyNotFoundOrLoadable = errors.New("Key not found and could not be loaded into cache")
+)
+
+// 加载被取消或超时：调用方的context已结束
+// 可通过errors.Is(err, context.Canceled) / errors.Is(err, context.DeadlineExceeded)判断具体原因
+type LoadCanceledError struct {
+	Key interface{}
+	Err error // ctx.Err()
+}
+
+func (e *LoadCanceledError) Error() string {
+	return fmt.Sprintf("cache: load of %v canceled: %v", e.Key, e.Err)
+}
+
+func (e *LoadCanceledError) Unwrap() error {
+	return e.Err
+}
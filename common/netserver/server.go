@@ -0,0 +1,287 @@
+package netserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 文本协议服务端的公共部分：监听、连接的登记及数量限制、优雅关闭
+// 协议只需实现Handler：在单独的goroutine中处理一个连接 每条命令之前调用Conn.Next
+// 见memcache.Server、resp.Server
+
+type Options struct {
+	Name        string        // 日志的前缀 如"memcache"
+	MaxConns    int           // 最大连接数 超过时写入Reject并关闭连接 <= 0表示不限制
+	Reject      string        // 拒绝连接时的响应(包括结尾的\r\n)
+	IdleTimeout time.Duration // 连接空闲超时 0表示不限制
+	Logger      *log.Logger   // 记录连接错误 nil表示不记录
+	ErrClosed   error         // Shutdown、Close之后Serve返回的错误
+	Handler     func(c *Conn) // 处理连接 返回后连接被关闭
+}
+
+type Server struct {
+	opts Options
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*Conn]struct{}
+	shutdown  atomic.Bool
+	done      chan struct{} // 所有连接都已结束时关闭(Shutdown中使用)
+
+	totalConns, rejectedConns atomic.Int64
+}
+
+// 一个连接：协议通过Next等待下一条命令
+type Conn struct {
+	net.Conn
+	s    *Server
+	idle atomic.Bool // 正在等待下一条命令：Shutdown时可以直接关闭
+}
+
+func New(opts Options) *Server {
+	return &Server{
+		opts:      opts,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*Conn]struct{}),
+	}
+}
+
+// 处理l上的连接 直至l出错或服务端被关闭 返回时l已被关闭
+func (s *Server) Serve(l net.Listener) error {
+	if !s.trackListener(l, true) {
+		l.Close()
+		return s.opts.ErrClosed
+	}
+	defer s.trackListener(l, false)
+
+	var delay time.Duration // 临时错误时的重试间隔
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if s.shutdown.Load() {
+				return s.opts.ErrClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+				s.Logf("%s: accept error: %v; retrying in %v", s.opts.Name, err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			l.Close()
+			return err
+		}
+		delay = 0
+
+		c := s.newConn(nc)
+		if c == nil {
+			continue
+		}
+		go func() {
+			defer func() {
+				nc.Close()
+				s.removeConn(c)
+			}()
+			s.opts.Handler(c)
+		}()
+	}
+}
+
+// 优雅关闭：停止接受新连接 关闭空闲的连接 等待正在执行的命令结束
+// ctx结束时强制关闭剩余的连接并返回ctx.Err()
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shutdown.Store(true)
+	s.closeListeners()
+	if s.done == nil {
+		s.done = make(chan struct{})
+		if len(s.conns) == 0 {
+			close(s.done)
+		}
+	}
+	done := s.done
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.closeIdleConns()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// 立即关闭所有监听及连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shutdown.Store(true)
+	s.closeListeners()
+	for c := range s.conns {
+		c.Conn.Close()
+	}
+	return nil
+}
+
+// 当前的连接数
+func (s *Server) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+// 正在执行命令(不空闲)的连接数
+func (s *Server) ActiveConns() (n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		if !c.idle.Load() {
+			n++
+		}
+	}
+	return n
+}
+
+// 接受的连接总数(不包括被拒绝的连接)
+func (s *Server) TotalConns() int64 {
+	return s.totalConns.Load()
+}
+
+// 因超过MaxConns被拒绝的连接数
+func (s *Server) RejectedConns() int64 {
+	return s.rejectedConns.Load()
+}
+
+func (s *Server) Logf(format string, v ...interface{}) {
+	if s.opts.Logger != nil {
+		s.opts.Logger.Printf(format, v...)
+	}
+}
+
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !add {
+		delete(s.listeners, l)
+		return true
+	}
+	if s.shutdown.Load() {
+		return false
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+// 必须持有锁
+func (s *Server) closeListeners() {
+	for l := range s.listeners {
+		l.Close()
+		delete(s.listeners, l)
+	}
+}
+
+// 关闭等待下一条命令的连接
+func (s *Server) closeIdleConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		if c.idle.Load() {
+			c.Conn.Close()
+		}
+	}
+}
+
+// 登记新连接 超过连接数限制或已关闭时拒绝并返回nil
+func (s *Server) newConn(nc net.Conn) *Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shutdown.Load() {
+		nc.Close()
+		return nil
+	}
+	if s.opts.MaxConns > 0 && len(s.conns) >= s.opts.MaxConns {
+		s.rejectedConns.Add(1)
+		go s.reject(nc) // 不在锁内写
+		return nil
+	}
+
+	c := &Conn{Conn: nc, s: s}
+	s.conns[c] = struct{}{}
+	s.totalConns.Add(1)
+	return c
+}
+
+// 返回错误后关闭 客户端可以区分拒绝和网络错误
+func (s *Server) reject(nc net.Conn) {
+	nc.SetWriteDeadline(time.Now().Add(time.Second))
+	io.WriteString(nc, s.opts.Reject)
+	nc.Close()
+}
+
+func (s *Server) removeConn(c *Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, c)
+	if s.done != nil && len(s.conns) == 0 {
+		select {
+		case <-s.done:
+		default:
+			close(s.done)
+		}
+	}
+}
+
+// ========================================Conn=====================================
+
+// 等待下一条命令：r中没有pipeline的命令时 先发送w中的响应 然后空闲地等待命令的第一个字节
+// 返回false时应结束连接：连接出错(已记录)或服务端正在关闭
+func (c *Conn) Next(r *bufio.Reader, w *bufio.Writer) bool {
+	if c.s.opts.IdleTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(c.s.opts.IdleTimeout))
+	}
+	if r.Buffered() > 0 {
+		return true
+	}
+	if err := w.Flush(); err != nil {
+		return false
+	}
+	c.idle.Store(true)
+	if c.s.shutdown.Load() {
+		return false
+	}
+	_, err := r.Peek(1) // 收到命令的第一个字节后不再空闲
+	c.idle.Store(false)
+	if err != nil {
+		c.LogError(err)
+		return false
+	}
+	return true
+}
+
+// 记录连接关闭、超时以外的读取错误
+func (c *Conn) LogError(err error) {
+	if err != io.EOF && !errors.Is(err, net.ErrClosed) && !errors.Is(err, os.ErrDeadlineExceeded) {
+		c.s.Logf("%s: %v: %v", c.s.opts.Name, c.RemoteAddr(), err)
+	}
+}
@@ -1,5 +1,109 @@
 package main
 
+import (
+	"code-utils-demos/cache"
+	"code-utils-demos/memcache"
+	"code-utils-demos/resp"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// 基于LRUCache的memcached文本协议服务 以及可选的基于cache_go table的RESP(Redis协议)服务 例如：
+//
+//	go run main-cache.go -addr :11211 -memory 64 -resp-addr :6379
+//	printf 'set k 0 0 1\r\nv\r\nget k\r\n' | nc localhost 11211
+//	redis-cli -p 6379 set k v EX 60
+var (
+	addr            = flag.String("addr", ":11211", "memcached protocol listen address")
+	memory          = flag.Int64("memory", 64, "memory for items in megabytes")
+	maxConns        = flag.Int("max-conns", 1024, "max simultaneous connections per server (0 = unlimited)")
+	maxItemSize     = flag.Int("max-item-size", memcache.DefaultMaxItemSize, "max value size in bytes")
+	idleTimeout     = flag.Duration("idle-timeout", 0, "close connections idle for this long (0 = never)")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "time to wait for in-flight commands on shutdown")
+	respAddr        = flag.String("resp-addr", "", "RESP (Redis protocol) listen address backed by cache_go tables (empty = disabled)")
+)
+
+// memcache.Server、resp.Server
+type server interface {
+	Serve(l net.Listener) error
+	Shutdown(ctx context.Context) error
+}
+
+type service struct {
+	name string
+	addr string
+	srv  server
+	l    net.Listener
+}
+
 func main() {
+	flag.Parse()
+
+	c, err := cache.NewLRUCache[string, *memcache.Item](*memory << 20)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	services := []service{{name: "memcache", addr: *addr, srv: memcache.NewServer(c, memcache.Options{
+		MaxConns:    *maxConns,
+		MaxItemSize: *maxItemSize,
+		IdleTimeout: *idleTimeout,
+		Logger:      log.Default(),
+	})}}
+	if *respAddr != "" {
+		services = append(services, service{name: "resp", addr: *respAddr, srv: resp.NewServer(resp.Options{
+			MaxConns:    *maxConns,
+			IdleTimeout: *idleTimeout,
+			Logger:      log.Default(),
+		})})
+	}
+
+	// 先监听所有地址：任何一个失败都不启动
+	for i := range services {
+		l, err := net.Listen("tcp", services[i].addr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		services[i].l = l
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, len(services))
+	for _, s := range services {
+		go func(s service) {
+			errc <- s.srv.Serve(s.l)
+		}(s)
+		log.Printf("%s: listening on %s", s.name, s.l.Addr())
+	}
+
+	select {
+	case err := <-errc:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
 
+	// 优雅关闭：等待正在执行的命令结束
+	log.Print("shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	for _, s := range services {
+		if err := s.srv.Shutdown(sctx); err != nil {
+			log.Printf("%s: shutdown: %v", s.name, err)
+		}
+	}
+	for range services {
+		if err := <-errc; !errors.Is(err, memcache.ErrServerClosed) && !errors.Is(err, resp.ErrServerClosed) {
+			log.Print(err)
+		}
+	}
 }
@@ -0,0 +1,467 @@
+package memcache
+
+import (
+	"bufio"
+	"code-utils-demos/cache"
+	"code-utils-demos/common/netserver"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// exptime超过30天时为unix时间戳 否则为相对的秒数
+const maxRelativeExptime = 30 * 24 * 60 * 60
+
+var errLineTooLong = errors.New("memcache: line too long")
+
+type conn struct {
+	s  *Server
+	nc *netserver.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+// 统计读写的字节数
+type countingConn struct {
+	net.Conn
+	read, written *atomic.Int64
+}
+
+func (c countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func (c countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// 连接由netserver关闭
+func (c *conn) serve() {
+	for c.nc.Next(c.r, c.w) {
+		line, err := c.readLine()
+		if err != nil {
+			if errors.Is(err, errLineTooLong) {
+				c.reply("CLIENT_ERROR line too long")
+				c.w.Flush()
+			} else {
+				c.nc.LogError(err)
+			}
+			return
+		}
+		if quit := c.dispatch(line); quit {
+			c.w.Flush()
+			return
+		}
+	}
+}
+
+// 读取一行 去掉结尾的\r\n
+func (c *conn) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", errLineTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return string(line), nil
+}
+
+func (c *conn) reply(s string) {
+	c.w.WriteString(s)
+	c.w.WriteString("\r\n")
+}
+
+// 执行一条命令 返回是否关闭连接
+func (c *conn) dispatch(line string) (quit bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		c.reply("ERROR")
+		return false
+	}
+
+	args := fields[1:]
+	switch cmd := fields[0]; cmd {
+	case "get", "gets":
+		c.get(args, cmd == "gets")
+	case "set", "add", "replace", "cas":
+		return c.store(cmd, args)
+	case "delete":
+		c.delete(args)
+	case "incr", "decr":
+		c.incr(args, cmd == "incr")
+	case "touch":
+		c.touch(args)
+	case "stats":
+		c.statsCmd(args)
+	case "version":
+		c.reply("VERSION " + Version)
+	case "quit":
+		return true
+	default:
+		c.reply("ERROR")
+	}
+	return false
+}
+
+// 去掉结尾的noreply 返回剩余的参数
+func noreply(args []string, n int) ([]string, bool) {
+	if len(args) == n+1 && args[n] == "noreply" {
+		return args[:n], true
+	}
+	return args, false
+}
+
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > MaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// ========================================命令=====================================
+
+// get <key>* / gets <key>*
+func (c *conn) get(keys []string, withCAS bool) {
+	if len(keys) == 0 {
+		c.reply("ERROR")
+		return
+	}
+	for _, key := range keys {
+		if !validKey(key) {
+			c.reply("CLIENT_ERROR bad command line format")
+			return
+		}
+	}
+
+	for _, key := range keys {
+		c.s.stats.cmdGet.Add(1)
+		item, ok := c.s.c.Get(key)
+		if !ok {
+			c.s.stats.getMisses.Add(1)
+			continue
+		}
+		c.s.stats.getHits.Add(1)
+
+		c.w.WriteString("VALUE ")
+		c.w.WriteString(key)
+		c.w.WriteString(" ")
+		c.w.WriteString(strconv.FormatUint(uint64(item.Flags), 10))
+		c.w.WriteString(" ")
+		c.w.WriteString(strconv.Itoa(len(item.Value)))
+		if withCAS {
+			c.w.WriteString(" ")
+			c.w.WriteString(strconv.FormatUint(item.CAS, 10))
+		}
+		c.w.WriteString("\r\n")
+		c.w.Write(item.Value)
+		c.w.WriteString("\r\n")
+	}
+	c.reply("END")
+}
+
+// <set|add|replace> <key> <flags> <exptime> <bytes> [noreply]
+// cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+// 返回是否关闭连接：数据块不完整时无法继续解析
+func (c *conn) store(cmd string, args []string) (quit bool) {
+	n := 4
+	if cmd == "cas" {
+		n = 5
+	}
+	args, quiet := noreply(args, n)
+	if len(args) != n {
+		c.reply("ERROR")
+		return false
+	}
+
+	key := args[0]
+	flags, err1 := strconv.ParseUint(args[1], 10, 32)
+	exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+	size, err3 := strconv.Atoi(args[3])
+	var cas uint64
+	var err4 error
+	if cmd == "cas" {
+		cas, err4 = strconv.ParseUint(args[4], 10, 64)
+	}
+	if !validKey(key) || err1 != nil || err2 != nil || err3 != nil || err4 != nil || size < 0 {
+		c.reply("CLIENT_ERROR bad command line format")
+		return false
+	}
+
+	c.s.stats.cmdSet.Add(1)
+	if size > c.s.opts.MaxItemSize { // 丢弃数据块
+		if _, err := c.r.Discard(size + 2); err != nil {
+			return true
+		}
+		c.reply("SERVER_ERROR object too large for cache")
+		return false
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return true
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		c.reply("CLIENT_ERROR bad data chunk")
+		return false
+	}
+	value := data[:size:size]
+
+	result := c.s.store(cmd, key, uint32(flags), exptime, value, cas)
+	if !quiet {
+		c.reply(result)
+	}
+	return false
+}
+
+// delete <key> [noreply]
+func (c *conn) delete(args []string) {
+	args, quiet := noreply(args, 1)
+	if len(args) != 1 || !validKey(args[0]) {
+		c.reply("CLIENT_ERROR bad command line format")
+		return
+	}
+	key := args[0]
+
+	mu := c.s.lock(key)
+	mu.Lock()
+	_, _, ok := c.s.get(key)
+	if ok {
+		c.s.c.Erase(key)
+	}
+	mu.Unlock()
+
+	result := "NOT_FOUND"
+	if ok {
+		c.s.stats.deleteHits.Add(1)
+		result = "DELETED"
+	} else {
+		c.s.stats.deleteMisses.Add(1)
+	}
+	if !quiet {
+		c.reply(result)
+	}
+}
+
+// <incr|decr> <key> <value> [noreply]
+// incr溢出时回绕 decr最小为0
+func (c *conn) incr(args []string, incr bool) {
+	args, quiet := noreply(args, 2)
+	if len(args) != 2 || !validKey(args[0]) {
+		c.reply("CLIENT_ERROR bad command line format")
+		return
+	}
+	key := args[0]
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		c.reply("CLIENT_ERROR invalid numeric delta argument")
+		return
+	}
+
+	result := c.s.incr(key, delta, incr)
+	if !quiet || strings.HasPrefix(result, "CLIENT_ERROR") {
+		c.reply(result)
+	}
+}
+
+// touch <key> <exptime> [noreply]
+func (c *conn) touch(args []string) {
+	args, quiet := noreply(args, 2)
+	if len(args) != 2 || !validKey(args[0]) {
+		c.reply("CLIENT_ERROR bad command line format")
+		return
+	}
+	key := args[0]
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.reply("CLIENT_ERROR invalid exptime argument")
+		return
+	}
+
+	c.s.stats.cmdTouch.Add(1)
+	mu := c.s.lock(key)
+	mu.Lock()
+	item, _, ok := c.s.get(key)
+	if ok {
+		err = c.s.put(key, item, exptime) // 不改变CAS
+	}
+	mu.Unlock()
+
+	result := "NOT_FOUND"
+	switch {
+	case err != nil:
+		result = serverError(err)
+	case ok:
+		c.s.stats.touchHits.Add(1)
+		result = "TOUCHED"
+	default:
+		c.s.stats.touchMisses.Add(1)
+	}
+	if !quiet {
+		c.reply(result)
+	}
+}
+
+// stats
+func (c *conn) statsCmd(args []string) {
+	if len(args) != 0 {
+		c.reply("ERROR")
+		return
+	}
+
+	for _, stat := range c.s.Stats() {
+		c.w.WriteString("STAT ")
+		c.w.WriteString(stat.Name)
+		c.w.WriteString(" ")
+		c.w.WriteString(stat.Value)
+		c.w.WriteString("\r\n")
+	}
+	c.reply("END")
+}
+
+// ========================================cache操作=====================================
+
+// 查询entry及其过期时间 不计入get的统计
+func (s *Server) get(key string) (item *Item, expires time.Time, ok bool) {
+	_, h, ok := s.c.Lookup_(key)
+	if !ok {
+		return nil, expires, false
+	}
+	defer h.Close()
+	return h.Value(), h.ExpiresAt(), true
+}
+
+// 保存entry exptime < 0或已过去的时间戳表示立即过期：删除已有的entry
+// 必须持有key的分段锁
+func (s *Server) put(key string, item *Item, exptime int64) error {
+	var ttl time.Duration
+	switch {
+	case exptime < 0:
+		ttl = -1
+	case exptime > maxRelativeExptime:
+		ttl = time.Until(time.Unix(exptime, 0))
+		if ttl <= 0 {
+			ttl = -1
+		}
+	case exptime > 0:
+		ttl = time.Duration(exptime) * time.Second
+	}
+	return s.putTTL(key, item, ttl)
+}
+
+// ttl为0表示不过期 < 0表示立即过期
+func (s *Server) putTTL(key string, item *Item, ttl time.Duration) error {
+	if ttl < 0 {
+		s.c.Erase(key)
+		return nil
+	}
+	var opts []cache.EntryOption
+	if ttl > 0 {
+		opts = append(opts, cache.WithTTL(ttl))
+	}
+	return s.c.SetWithOptions(key, item, len(key)+len(item.Value)+itemOverhead, nil, opts...)
+}
+
+func (s *Server) store(cmd, key string, flags uint32, exptime int64, value []byte, cas uint64) string {
+	mu := s.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	old, _, exists := s.get(key)
+	switch cmd {
+	case "add":
+		if exists {
+			return "NOT_STORED"
+		}
+	case "replace":
+		if !exists {
+			return "NOT_STORED"
+		}
+	case "cas":
+		if !exists {
+			s.stats.casMisses.Add(1)
+			return "NOT_FOUND"
+		}
+		if old.CAS != cas {
+			s.stats.casBadval.Add(1)
+			return "EXISTS"
+		}
+		s.stats.casHits.Add(1)
+	}
+
+	item := &Item{Flags: flags, Value: value, CAS: s.cas.Add(1)}
+	if err := s.put(key, item, exptime); err != nil {
+		return serverError(err)
+	}
+	return "STORED"
+}
+
+// 返回新的值或错误
+func (s *Server) incr(key string, delta uint64, incr bool) string {
+	hits, misses := &s.stats.decrHits, &s.stats.decrMisses
+	if incr {
+		hits, misses = &s.stats.incrHits, &s.stats.incrMisses
+	}
+
+	mu := s.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	old, expires, ok := s.get(key)
+	if !ok {
+		misses.Add(1)
+		return "NOT_FOUND"
+	}
+	n, err := strconv.ParseUint(string(old.Value), 10, 64)
+	if err != nil {
+		return "CLIENT_ERROR cannot increment or decrement non-numeric value"
+	}
+	hits.Add(1)
+
+	switch {
+	case incr:
+		n += delta
+	case delta > n:
+		n = 0
+	default:
+		n -= delta
+	}
+	value := strconv.FormatUint(n, 10)
+
+	// 保留原有的过期时间
+	var ttl time.Duration
+	if !expires.IsZero() {
+		if ttl = time.Until(expires); ttl <= 0 {
+			ttl = -1
+		}
+	}
+	item := &Item{Flags: old.Flags, Value: []byte(value), CAS: s.cas.Add(1)}
+	if err := s.putTTL(key, item, ttl); err != nil {
+		return serverError(err)
+	}
+	return value
+}
+
+func serverError(err error) string {
+	if errors.Is(err, cache.ErrEntryTooLarge) {
+		return "SERVER_ERROR out of memory storing object"
+	}
+	return "SERVER_ERROR " + err.Error()
+}
@@ -0,0 +1,213 @@
+package memcache
+
+import (
+	"bufio"
+	"code-utils-demos/cache"
+	"code-utils-demos/common/netserver"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memcached文本协议的服务端：数据保存在LRUCache中 容量以字节计算
+// 支持的命令：get gets set add replace cas delete incr decr touch stats version quit
+// See https://github.com/memcached/memcached/blob/master/doc/protocol.txt
+//
+// 每个entry保存一个CAS token：每次修改(touch除外)时分配新的token gets返回 cas命令据此判断是否被修改过
+// 修改命令按key分段加锁 保证add、replace、cas、incr、decr的检查与写入是原子的
+// 注：直接修改底层cache(不通过Server)不受分段锁的保护
+
+// 服务端版本 version及stats返回
+const Version = "1.6.0-lru"
+
+const (
+	DefaultMaxItemSize = 1 << 20 // value的最大字节数
+	MaxKeyLength       = 250
+
+	// 命令行的最大长度(包括一次get多个key)
+	maxLineLength = 64 << 10
+
+	// 每个entry除key和value之外的额外开销 计入entry的size
+	itemOverhead = 48
+
+	lockStripes = 256
+)
+
+// Shutdown、Close之后Serve返回ErrServerClosed
+var ErrServerClosed = errors.New("memcache: server closed")
+
+// cache中保存的entry 保存后不再修改(修改时替换为新的Item) 可以并发读取
+type Item struct {
+	Flags uint32
+	Value []byte
+	CAS   uint64
+}
+
+type Options struct {
+	MaxConns    int           // 最大连接数 超过时返回SERVER_ERROR并关闭连接 <= 0表示不限制
+	MaxItemSize int           // value的最大字节数 <= 0时使用DefaultMaxItemSize
+	IdleTimeout time.Duration // 连接空闲超时 0表示不限制
+	Logger      *log.Logger   // 记录连接错误 nil表示不记录
+}
+
+type Server struct {
+	c    *cache.LRUCache[string, *Item]
+	opts Options
+
+	locks [lockStripes]sync.Mutex // 修改命令按key分段加锁
+	cas   atomic.Uint64           // 最后分配的CAS token
+
+	srv *netserver.Server // 监听及连接的管理
+
+	started time.Time
+	stats   counters
+}
+
+// 运行计数 均为原子操作
+type counters struct {
+	cmdGet, cmdSet, cmdTouch                   atomic.Int64
+	getHits, getMisses                         atomic.Int64
+	deleteHits, deleteMisses                   atomic.Int64
+	incrHits, incrMisses, decrHits, decrMisses atomic.Int64
+	casHits, casMisses, casBadval              atomic.Int64
+	touchHits, touchMisses                     atomic.Int64
+	bytesRead, bytesWritten                    atomic.Int64
+}
+
+// 创建服务端 c的capacity为可使用的内存(字节)
+func NewServer(c *cache.LRUCache[string, *Item], opts Options) *Server {
+	if opts.MaxItemSize <= 0 {
+		opts.MaxItemSize = DefaultMaxItemSize
+	}
+	s := &Server{
+		c:       c,
+		opts:    opts,
+		started: time.Now(),
+	}
+	s.srv = netserver.New(netserver.Options{
+		Name:        "memcache",
+		MaxConns:    opts.MaxConns,
+		Reject:      "SERVER_ERROR Too many open connections\r\n", // 与memcached相同
+		IdleTimeout: opts.IdleTimeout,
+		Logger:      opts.Logger,
+		ErrClosed:   ErrServerClosed,
+		Handler:     s.serveConn,
+	})
+	return s
+}
+
+// 底层的cache
+func (s *Server) Cache() *cache.LRUCache[string, *Item] {
+	return s.c
+}
+
+// 监听addr并处理连接
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// 处理l上的连接 直至l出错或服务端被关闭 返回时l已被关闭
+func (s *Server) Serve(l net.Listener) error {
+	return s.srv.Serve(l)
+}
+
+// 优雅关闭：停止接受新连接 关闭空闲的连接 等待正在执行的命令结束
+// ctx结束时强制关闭剩余的连接并返回ctx.Err()
+// 注：不关闭底层的cache
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// 立即关闭所有监听及连接
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// 当前的连接数
+func (s *Server) Conns() int {
+	return s.srv.Conns()
+}
+
+// 处理一个连接 见netserver.Options.Handler
+func (s *Server) serveConn(nc *netserver.Conn) {
+	cc := countingConn{nc, &s.stats.bytesRead, &s.stats.bytesWritten}
+	c := &conn{
+		s:  s,
+		nc: nc,
+		r:  bufio.NewReaderSize(cc, maxLineLength),
+		w:  bufio.NewWriter(cc),
+	}
+	c.serve()
+}
+
+// stats命令的一项
+type Stat struct {
+	Name  string
+	Value string
+}
+
+// stats命令的输出 名字与memcached相同
+func (s *Server) Stats() []Stat {
+	now := time.Now()
+	cs := s.c.StatsSnapshot()
+	n := func(v *atomic.Int64) string {
+		return strconv.FormatInt(v.Load(), 10)
+	}
+	i := func(v int64) string {
+		return strconv.FormatInt(v, 10)
+	}
+	return []Stat{
+		{"pid", strconv.Itoa(os.Getpid())},
+		{"uptime", i(int64(now.Sub(s.started) / time.Second))},
+		{"time", i(now.Unix())},
+		{"version", Version},
+		{"curr_connections", strconv.Itoa(s.Conns())},
+		{"total_connections", i(s.srv.TotalConns())},
+		{"rejected_connections", i(s.srv.RejectedConns())},
+		{"cmd_get", n(&s.stats.cmdGet)},
+		{"cmd_set", n(&s.stats.cmdSet)},
+		{"cmd_touch", n(&s.stats.cmdTouch)},
+		{"get_hits", n(&s.stats.getHits)},
+		{"get_misses", n(&s.stats.getMisses)},
+		{"delete_misses", n(&s.stats.deleteMisses)},
+		{"delete_hits", n(&s.stats.deleteHits)},
+		{"incr_misses", n(&s.stats.incrMisses)},
+		{"incr_hits", n(&s.stats.incrHits)},
+		{"decr_misses", n(&s.stats.decrMisses)},
+		{"decr_hits", n(&s.stats.decrHits)},
+		{"cas_misses", n(&s.stats.casMisses)},
+		{"cas_hits", n(&s.stats.casHits)},
+		{"cas_badval", n(&s.stats.casBadval)},
+		{"touch_hits", n(&s.stats.touchHits)},
+		{"touch_misses", n(&s.stats.touchMisses)},
+		{"bytes_read", n(&s.stats.bytesRead)},
+		{"bytes_written", n(&s.stats.bytesWritten)},
+		{"limit_maxbytes", i(cs.Capacity)},
+		{"item_size_max", strconv.Itoa(s.opts.MaxItemSize)},
+		{"curr_items", i(cs.Length)},
+		{"total_items", i(cs.Inserts)},
+		{"bytes", i(cs.Size)},
+		{"evictions", i(cs.Evictions)},
+		{"expired_unfetched", i(cs.Expirations)},
+	}
+}
+
+// key所在分段的锁
+func (s *Server) lock(key string) *sync.Mutex {
+	h := uint32(2166136261) // FNV-1a 32
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &s.locks[h%lockStripes]
+}
@@ -0,0 +1,262 @@
+package memcache
+
+import (
+	"bufio"
+	"code-utils-demos/cache"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 在127.0.0.1:0上启动服务端 返回服务端、地址及Serve的返回值
+func startServer(t *testing.T, opts Options) (*Server, string, <-chan error) {
+	t.Helper()
+	c, err := cache.NewLRUCache[string, *Item](1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(c, opts)
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(l) }()
+	t.Cleanup(func() {
+		s.Close()
+		c.Close()
+	})
+	return s, l.Addr().String(), served
+}
+
+// 等待正在执行命令(不空闲)的连接数为n
+func waitActive(t *testing.T, s *Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.srv.ActiveConns() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("ActiveConns = %d, want %d", s.srv.ActiveConns(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 在连接空闲后发送不完整的命令 等待服务端开始执行
+func sendPartial(t *testing.T, s *Server, c *client, cmd string) {
+	t.Helper()
+	waitActive(t, s, 0)
+	c.send(cmd)
+	waitActive(t, s, 1)
+}
+
+type client struct {
+	t  *testing.T
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *client {
+	t.Helper()
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { nc.Close() })
+	return &client{t: t, nc: nc, r: bufio.NewReader(nc)}
+}
+
+func (c *client) send(s string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.nc, s); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) line() string {
+	c.t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+// 发送一条命令 读取n行响应
+func (c *client) do(cmd string, n int) []string {
+	c.t.Helper()
+	c.send(cmd + "\r\n")
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = c.line()
+	}
+	return lines
+}
+
+func (c *client) expect(cmd string, want ...string) {
+	c.t.Helper()
+	if got := c.do(cmd, len(want)); strings.Join(got, "|") != strings.Join(want, "|") {
+		c.t.Fatalf("%q = %q, want %q", cmd, got, want)
+	}
+}
+
+// gets返回的CAS token
+func (c *client) casOf(key string) string {
+	c.t.Helper()
+	lines := c.do("gets "+key, 3)
+	fields := strings.Fields(lines[0])
+	if len(fields) != 5 || lines[2] != "END" {
+		c.t.Fatalf("gets %s = %q", key, lines)
+	}
+	return fields[4]
+}
+
+func TestServerCommands(t *testing.T) {
+	_, addr, _ := startServer(t, Options{})
+	c := dial(t, addr)
+
+	c.expect("get k", "END")
+	c.expect("set k 5 0 3\r\nabc", "STORED")
+	c.expect("get k missing", "VALUE k 5 3", "abc", "END")
+	c.expect("add k 0 0 1\r\nx", "NOT_STORED")
+	c.expect("add n 0 0 2\r\n10", "STORED")
+	c.expect("replace missing 0 0 1\r\nx", "NOT_STORED")
+	c.expect("replace k 7 0 3\r\nxyz", "STORED")
+	c.expect("get k", "VALUE k 7 3", "xyz", "END")
+
+	// cas
+	cas := c.casOf("k")
+	c.expect("cas k 0 0 1 "+cas+"\r\nA", "STORED")
+	c.expect("cas k 0 0 1 "+cas+"\r\nB", "EXISTS")
+	c.expect("cas missing 0 0 1 1\r\nB", "NOT_FOUND")
+	c.expect("get k", "VALUE k 0 1", "A", "END")
+
+	// incr、decr分配新的CAS touch不改变CAS
+	cas = c.casOf("n")
+	c.expect("incr n 5", "15")
+	c.expect("decr n 100", "0")
+	c.expect("incr k 1", "CLIENT_ERROR cannot increment or decrement non-numeric value")
+	c.expect("incr missing 1", "NOT_FOUND")
+	if c.casOf("n") == cas {
+		t.Error("incr did not change the CAS token")
+	}
+	cas = c.casOf("n")
+	c.expect("touch n 100", "TOUCHED")
+	c.expect("touch missing 100", "NOT_FOUND")
+	if c.casOf("n") != cas {
+		t.Error("touch changed the CAS token")
+	}
+
+	// noreply：下一条命令的响应紧随其后
+	c.expect("set q 0 0 1 noreply\r\nq\r\nget q", "VALUE q 0 1", "q", "END")
+
+	// 立即过期
+	c.expect("set e 0 -1 1\r\ne", "STORED")
+	c.expect("get e", "END")
+
+	c.expect("delete k", "DELETED")
+	c.expect("delete k", "NOT_FOUND")
+	c.expect("bogus", "ERROR")
+	c.expect("version", "VERSION "+Version)
+
+	c.send("stats\r\n")
+	stats := map[string]string{}
+	for line := c.line(); line != "END"; line = c.line() {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "STAT" {
+			t.Fatalf("stats line %q", line)
+		}
+		stats[fields[1]] = fields[2]
+	}
+	for name, want := range map[string]string{"curr_connections": "1", "cas_hits": "1", "cas_badval": "1", "cas_misses": "1", "touch_hits": "1", "delete_hits": "1"} {
+		if stats[name] != want {
+			t.Errorf("STAT %s = %q, want %q", name, stats[name], want)
+		}
+	}
+
+	c.send("quit\r\n")
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("read after quit: %v, want EOF", err)
+	}
+}
+
+func TestServerMaxConns(t *testing.T) {
+	s, addr, _ := startServer(t, Options{MaxConns: 1})
+	c1 := dial(t, addr)
+	c1.expect("version", "VERSION "+Version) // 确保已登记
+
+	c2 := dial(t, addr)
+	if got := c2.line(); got != "SERVER_ERROR Too many open connections" {
+		t.Fatalf("rejected conn got %q", got)
+	}
+	if _, err := c2.r.ReadByte(); err != io.EOF {
+		t.Errorf("rejected conn read: %v, want EOF", err)
+	}
+	if s.Conns() != 1 {
+		t.Errorf("Conns = %d, want 1", s.Conns())
+	}
+	c1.expect("get k", "END") // 已有的连接不受影响
+}
+
+// Shutdown关闭空闲的连接 等待正在执行的命令结束
+func TestServerShutdownDrains(t *testing.T) {
+	s, addr, served := startServer(t, Options{})
+	idle := dial(t, addr)
+	idle.expect("version", "VERSION "+Version)
+	busy := dial(t, addr)
+	busy.expect("version", "VERSION "+Version)
+	sendPartial(t, s, busy, "set k 0 0 5\r\nab") // 命令执行中：数据块尚未读完
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+
+	if _, err := idle.r.ReadByte(); err != io.EOF { // 空闲的连接被立即关闭
+		t.Fatalf("idle conn read: %v, want EOF", err)
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v while a command was in progress", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	busy.send("cde\r\n")
+	if got := busy.line(); got != "STORED" {
+		t.Fatalf("in-progress set = %q, want STORED", got)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown = %v", err)
+	}
+	if _, err := busy.r.ReadByte(); err != io.EOF {
+		t.Errorf("busy conn read after Shutdown: %v, want EOF", err)
+	}
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve = %v, want ErrServerClosed", err)
+	}
+	if _, ok := s.Cache().Get("k"); !ok {
+		t.Error("value stored during Shutdown missing")
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("listener still accepting after Shutdown")
+	}
+}
+
+// ctx结束时强制关闭剩余的连接
+func TestServerShutdownTimeout(t *testing.T) {
+	s, addr, _ := startServer(t, Options{})
+	busy := dial(t, addr)
+	busy.expect("version", "VERSION "+Version)
+	sendPartial(t, s, busy, "set k 0 0 5\r\nab")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want DeadlineExceeded", err)
+	}
+	if _, err := busy.r.ReadByte(); err == nil {
+		t.Error("busy conn still open after forced Shutdown")
+	}
+}
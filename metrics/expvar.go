@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// expvar.Publish对重复的name会panic：检查与发布在同一个临界区内
+var publishMu sync.Mutex
+
+// 以expvar发布Registry：/debug/vars中的name字段即为Snapshot
+// 每次访问时重新收集 name已被发布时返回错误
+func (r *Registry) PublishExpvar(name string) error {
+	publishMu.Lock()
+	defer publishMu.Unlock()
+
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("metrics: expvar %q already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() any {
+		return r.Snapshot()
+	}))
+	return nil
+}
+
+// 以expvar发布默认的Registry
+func PublishExpvar(name string) error {
+	return Default.PublishExpvar(name)
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"code-utils-demos/cache"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// 可导出统计信息的cache：LRUCache、ShardedLRUCache
+type CacheSource interface {
+	StatsSnapshot() cache.StatsSnapshot
+}
+
+// 可导出item个数的table：cache_go.CacheTable
+type TableSource interface {
+	Count() int
+}
+
+// 注册的cache和table 按名字区分
+type Registry struct {
+	mu     sync.RWMutex
+	caches map[string]CacheSource
+	tables map[string]TableSource
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		caches: make(map[string]CacheSource),
+		tables: make(map[string]TableSource),
+	}
+}
+
+// 默认的Registry：包级别的Register*、Handler、PublishExpvar均使用它
+var Default = NewRegistry()
+
+// 注册cache 名字重复时返回错误
+func (r *Registry) RegisterCache(name string, c CacheSource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.caches[name]; ok {
+		return fmt.Errorf("metrics: cache %q already registered", name)
+	}
+	r.caches[name] = c
+	return nil
+}
+
+// 注册table 名字重复时返回错误
+func (r *Registry) RegisterTable(name string, t TableSource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tables[name]; ok {
+		return fmt.Errorf("metrics: table %q already registered", name)
+	}
+	r.tables[name] = t
+	return nil
+}
+
+// 注销cache 返回是否存在
+func (r *Registry) UnregisterCache(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.caches[name]
+	delete(r.caches, name)
+	return ok
+}
+
+// 注销table 返回是否存在
+func (r *Registry) UnregisterTable(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.tables[name]
+	delete(r.tables, name)
+	return ok
+}
+
+// 查询已注册的cache
+func (r *Registry) Cache(name string) (CacheSource, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.caches[name]
+	return c, ok
+}
+
+// 查询已注册的table
+func (r *Registry) Table(name string) (TableSource, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.tables[name]
+	return t, ok
+}
+
+// 已注册的cache和table的名字 按名字排序
+func (r *Registry) Names() (caches, tables []string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return sortedKeys(r.caches), sortedKeys(r.tables)
+}
+
+// 某一时刻所有cache和table的统计信息
+type Snapshot struct {
+	Caches map[string]cache.StatsSnapshot
+	Tables map[string]int
+}
+
+// 收集所有已注册的cache和table的统计信息
+// 注：在锁外读取各cache的统计信息 避免注册与采集互相阻塞
+func (r *Registry) Snapshot() Snapshot {
+	r.mu.RLock()
+	caches := make(map[string]CacheSource, len(r.caches))
+	for name, c := range r.caches {
+		caches[name] = c
+	}
+	tables := make(map[string]TableSource, len(r.tables))
+	for name, t := range r.tables {
+		tables[name] = t
+	}
+	r.mu.RUnlock()
+
+	s := Snapshot{
+		Caches: make(map[string]cache.StatsSnapshot, len(caches)),
+		Tables: make(map[string]int, len(tables)),
+	}
+	for name, c := range caches {
+		s.Caches[name] = c.StatsSnapshot()
+	}
+	for name, t := range tables {
+		s.Tables[name] = t.Count()
+	}
+	return s
+}
+
+func RegisterCache(name string, c CacheSource) error {
+	return Default.RegisterCache(name, c)
+}
+
+func RegisterTable(name string, t TableSource) error {
+	return Default.RegisterTable(name, t)
+}
+
+func UnregisterCache(name string) bool {
+	return Default.UnregisterCache(name)
+}
+
+func UnregisterTable(name string) bool {
+	return Default.UnregisterTable(name)
+}
+
+// 按名字排序 保证输出稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"code-utils-demos/cache"
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type table int
+
+func (t table) Count() int { return int(t) }
+
+type emptySource struct{}
+
+func (emptySource) StatsSnapshot() cache.StatsSnapshot { return cache.StatsSnapshot{} }
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	for _, name := range []string{"lru", `a"b\c`} {
+		c, err := cache.NewLRUCache[string, interface{}](100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		c.Set("x", 1, 10)
+		c.Get("x")
+		c.Get("missing")
+		if err := r.RegisterCache(name, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.RegisterTable("sessions\n", table(3)); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	r := newTestRegistry(t)
+	if err := r.RegisterCache("lru", emptySource{}); err == nil {
+		t.Error("RegisterCache with a duplicate name succeeded")
+	}
+	if err := r.RegisterTable("sessions\n", table(0)); err == nil {
+		t.Error("RegisterTable with a duplicate name succeeded")
+	}
+	if !r.UnregisterCache("lru") || r.UnregisterCache("lru") {
+		t.Error("UnregisterCache should report whether the cache existed")
+	}
+	if caches, tables := r.Names(); len(caches) != 1 || len(tables) != 1 {
+		t.Errorf("Names() = %q, %q", caches, tables)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := newTestRegistry(t)
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != contentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	out := rec.Body.String()
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+
+	// 每个指标：HELP、TYPE各一行 每个cache一个序列
+	for _, m := range cacheMetrics {
+		for _, prefix := range []string{"# HELP " + m.name + " ", "# TYPE " + m.name + " ", m.name + "{"} {
+			n := 0
+			for _, line := range lines {
+				if strings.HasPrefix(line, prefix) {
+					n++
+				}
+			}
+			want := 1
+			if prefix == m.name+"{" {
+				want = 2
+			}
+			if n != want {
+				t.Errorf("%d lines start with %q, want %d", n, prefix, want)
+			}
+		}
+	}
+	for _, line := range []string{
+		"# TYPE cache_hits_total counter",
+		"# TYPE cache_length gauge",
+		`cache_length{cache="lru"} 1`,
+		`cache_size{cache="lru"} 10`,
+		`cache_hits_total{cache="lru"} 1`,
+		`cache_misses_total{cache="lru"} 1`,
+		`cache_hits_total{cache="a\"b\\c"} 1`,
+		"# HELP cache_table_items Number of items in the table.",
+		"# TYPE cache_table_items gauge",
+		`cache_table_items{table="sessions\n"} 3`,
+	} {
+		found := false
+		for _, l := range lines {
+			found = found || l == line
+		}
+		if !found {
+			t.Errorf("missing line %q in:\n%s", line, out)
+		}
+	}
+	if len(lines) != 4*len(cacheMetrics)+3 { // HELP、TYPE、两个cache 以及table的三行
+		t.Errorf("got %d lines:\n%s", len(lines), out)
+	}
+
+	var empty strings.Builder
+	if err := NewRegistry().WritePrometheus(&empty); err != nil || empty.Len() != 0 {
+		t.Errorf("empty registry wrote %q, %v", empty.String(), err)
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	r := newTestRegistry(t)
+	if err := r.PublishExpvar("metrics_test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PublishExpvar("metrics_test"); err == nil {
+		t.Error("second PublishExpvar with the same name succeeded")
+	}
+
+	var got Snapshot
+	if err := json.Unmarshal([]byte(expvar.Get("metrics_test").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Caches) != 2 || got.Caches["lru"].Hits != 1 || got.Caches[`a"b\c`].Size != 10 {
+		t.Errorf("expvar caches = %+v", got.Caches)
+	}
+	if len(got.Tables) != 1 || got.Tables["sessions\n"] != 3 {
+		t.Errorf("expvar tables = %v", got.Tables)
+	}
+
+	r.UnregisterCache("lru") // 每次访问时重新收集
+	var after Snapshot
+	if err := json.Unmarshal([]byte(expvar.Get("metrics_test").String()), &after); err != nil || len(after.Caches) != 1 {
+		t.Errorf("after UnregisterCache: %+v, %v", after.Caches, err)
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bufio"
+	"code-utils-demos/cache"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Prometheus text exposition format的Content-Type
+// See https://prometheus.io/docs/instrumenting/exposition_formats/
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// cache的指标
+var cacheMetrics = []struct {
+	name  string
+	typ   string
+	help  string
+	value func(s cache.StatsSnapshot) float64
+}{
+	{"cache_length", "gauge", "Number of entries in the cache.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.Length) }},
+	{"cache_size", "gauge", "Total size of the entries in the cache.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.Size) }},
+	{"cache_capacity", "gauge", "Capacity of the cache.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.Capacity) }},
+	{"cache_hits_total", "counter", "Number of lookups that found an entry.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.Hits) }},
+	{"cache_misses_total", "counter", "Number of lookups that found no entry.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.Misses) }},
+	{"cache_inserts_total", "counter", "Number of inserted entries.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.Inserts) }},
+	{"cache_evictions_total", "counter", "Number of entries evicted because the cache was full.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.Evictions) }},
+	{"cache_expirations_total", "counter", "Number of entries removed because they expired.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.Expirations) }},
+	{"cache_erases_total", "counter", "Number of entries removed by Erase.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.Erases) }},
+	{"cache_loads_total", "counter", "Number of getter calls.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.Loads) }},
+	{"cache_load_errors_total", "counter", "Number of getter calls that failed.",
+		func(s cache.StatsSnapshot) float64 { return float64(s.LoadErrors) }},
+	{"cache_load_seconds_total", "counter", "Total time spent in getter calls.",
+		func(s cache.StatsSnapshot) float64 { return s.TotalLoadTime.Seconds() }},
+}
+
+// 以Prometheus text exposition format输出所有指标
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	s := r.Snapshot()
+	bw := bufio.NewWriter(w)
+
+	if len(s.Caches) > 0 {
+		names := sortedKeys(s.Caches)
+		for _, m := range cacheMetrics {
+			fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+			for _, name := range names {
+				fmt.Fprintf(bw, "%s{cache=\"%s\"} %s\n", m.name, escapeLabel(name), formatValue(m.value(s.Caches[name])))
+			}
+		}
+	}
+
+	if len(s.Tables) > 0 {
+		fmt.Fprintf(bw, "# HELP cache_table_items Number of items in the table.\n# TYPE cache_table_items gauge\n")
+		for _, name := range sortedKeys(s.Tables) {
+			fmt.Fprintf(bw, "cache_table_items{table=\"%s\"} %d\n", escapeLabel(name), s.Tables[name])
+		}
+	}
+	return bw.Flush()
+}
+
+// 输出Prometheus指标的http.Handler
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		r.WritePrometheus(w)
+	})
+}
+
+// 默认Registry的http.Handler 例如：http.Handle("/metrics", metrics.Handler())
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// label值中的\、"以及换行需要转义
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,578 @@
+package resp
+
+import (
+	cache_go "code-utils-demos/cachev2.0"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 命令的参数个数(包括命令名)：> 0表示恰好为arity < 0表示至少为-arity
+type command struct {
+	arity int
+	fn    func(c *conn, args []string)
+}
+
+var commands map[string]command
+
+func init() { // fn引用commands(COMMAND COUNT) 不能在声明时初始化
+	commands = map[string]command{
+		"PING":    {-1, (*conn).ping},
+		"ECHO":    {2, (*conn).echo},
+		"SELECT":  {2, (*conn).selectDB},
+		"GET":     {2, (*conn).get},
+		"SET":     {-3, (*conn).set},
+		"DEL":     {-2, (*conn).del},
+		"EXISTS":  {-2, (*conn).exists},
+		"TTL":     {2, (*conn).ttl},
+		"PTTL":    {2, (*conn).ttl},
+		"EXPIRE":  {3, (*conn).expire},
+		"DBSIZE":  {1, (*conn).dbsize},
+		"FLUSHDB": {-1, (*conn).flushdb},
+		"KEYS":    {2, (*conn).keys},
+		"SCAN":    {-2, (*conn).scan},
+		"INFO":    {-1, (*conn).info},
+		"COMMAND": {-1, (*conn).command},
+	}
+}
+
+// 执行一条命令 返回是否关闭连接
+func (c *conn) dispatch(args []string) (quit bool) {
+	c.s.stats.commands.Add(1)
+
+	name := strings.ToUpper(args[0])
+	if name == "QUIT" {
+		c.writeSimple("OK")
+		return true
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		var sb strings.Builder
+		for _, arg := range args[1:] {
+			fmt.Fprintf(&sb, "'%s' ", arg)
+		}
+		c.writeError(fmt.Sprintf("ERR unknown command '%s', with args beginning with: %s", args[0], sb.String()))
+		return false
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || len(args) < -cmd.arity {
+		c.writeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return false
+	}
+	cmd.fn(c, args)
+	return false
+}
+
+func (c *conn) table() *cache_go.CacheTable {
+	return c.s.Table(c.db)
+}
+
+// 设置了有效期(EX/PX、EXPIRE)的value：与过期时间一起保存
+// cache_go的lifeSpan自最后一次访问起计算(GET会延长) 因此由deadline决定是否过期 lifeSpan只用于table的清理
+type expiring struct {
+	data     interface{}
+	deadline time.Time
+}
+
+func (v *expiring) String() string { return toString(v.data) }
+
+// 保存到table的item：lifeSpan为0时不过期
+func addExpiring(t *cache_go.CacheTable, key string, lifeSpan time.Duration, data interface{}, nx bool) bool {
+	if lifeSpan > 0 {
+		data = &expiring{data, time.Now().Add(lifeSpan)}
+	}
+	if nx {
+		return t.NotFoundAdd(key, lifeSpan, data)
+	}
+	t.Add(key, lifeSpan, data)
+	return true
+}
+
+// item的剩余有效期 expires为false表示不过期 ttl <= 0表示已过期(尚未被table清理)
+func remaining(item *cache_go.CacheItem, now time.Time) (ttl time.Duration, expires bool) {
+	if v, ok := item.Data().(*expiring); ok {
+		return v.deadline.Sub(now), true
+	}
+	if lifeSpan := item.LifeSpan(); lifeSpan > 0 { // 其他程序添加的item：按cache_go的语义
+		return lifeSpan - now.Sub(item.AccessedOn()), true
+	}
+	return 0, false
+}
+
+// 查询未过期的item 不延长有效期
+// ttl为剩余的有效期 0表示不过期
+func peek(t *cache_go.CacheTable, key string) (item *cache_go.CacheItem, ttl time.Duration, ok bool) {
+	if item, ok = t.Peek(key); !ok {
+		return nil, 0, false
+	}
+	ttl, expires := remaining(item, time.Now())
+	if expires && ttl <= 0 {
+		return nil, 0, false
+	}
+	return item, ttl, true
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case *expiring:
+		return toString(v.data)
+	}
+	return fmt.Sprint(v)
+}
+
+func parseInt(c *conn, s string) (int64, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		c.writeError("ERR value is not an integer or out of range")
+		return 0, false
+	}
+	return n, true
+}
+
+// ========================================连接=====================================
+
+// PING [message]
+func (c *conn) ping(args []string) {
+	switch len(args) {
+	case 1:
+		c.writeSimple("PONG")
+	case 2:
+		c.writeBulk(args[1])
+	default:
+		c.writeError("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+// ECHO message
+func (c *conn) echo(args []string) {
+	c.writeBulk(args[1])
+}
+
+// SELECT index
+func (c *conn) selectDB(args []string) {
+	db, ok := parseInt(c, args[1])
+	if !ok {
+		return
+	}
+	if db < 0 || db >= int64(c.s.opts.Databases) {
+		c.writeError("ERR DB index is out of range")
+		return
+	}
+	c.db = int(db)
+	c.writeSimple("OK")
+}
+
+// COMMAND [subcommand] 不提供命令的文档：redis-cli启动时调用COMMAND DOCS 返回空即可
+func (c *conn) command(args []string) {
+	if len(args) > 1 && strings.EqualFold(args[1], "COUNT") {
+		c.writeInt(int64(len(commands) + 1)) // 包括QUIT
+		return
+	}
+	c.writeArrayHeader(0)
+}
+
+// ========================================key=====================================
+
+// GET key 不影响EX/PX设置的有效期 table设置了loader时可能加载
+func (c *conn) get(args []string) {
+	t := c.table()
+	_, exists := t.Peek(args[1])
+	_, _, live := peek(t, args[1])
+	if exists && !live { // 已过期 尚未被table清理：删除 避免Value延长其有效期
+		t.Delete(args[1])
+		c.s.stats.misses.Add(1)
+		c.writeNil()
+		return
+	}
+	item, err := t.Value(args[1])
+	if err != nil {
+		c.s.stats.misses.Add(1)
+		c.writeNil()
+		return
+	}
+	c.s.stats.hits.Add(1)
+	c.writeBulk(toString(item.Data()))
+}
+
+// SET key value [EX seconds | PX milliseconds] [NX]
+func (c *conn) set(args []string) {
+	key, value := args[1], args[2]
+	var lifeSpan time.Duration
+	var nx, expire bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case (opt == "EX" || opt == "PX") && !expire && i+1 < len(args):
+			n, ok := parseInt(c, args[i+1])
+			if !ok {
+				return
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			if n <= 0 || n > math.MaxInt64/int64(unit) {
+				c.writeError("ERR invalid expire time in 'set' command")
+				return
+			}
+			lifeSpan, expire = time.Duration(n)*unit, true
+			i++
+		case opt == "NX" && !nx:
+			nx = true
+		default:
+			c.writeError("ERR syntax error")
+			return
+		}
+	}
+
+	t := c.table()
+	if !addExpiring(t, key, lifeSpan, value, nx) {
+		if _, _, ok := peek(t, key); ok {
+			c.writeNil()
+			return
+		}
+		t.Delete(key) // 已过期 尚未被table清理
+		if !addExpiring(t, key, lifeSpan, value, nx) {
+			c.writeNil()
+			return
+		}
+	}
+	c.writeSimple("OK")
+}
+
+// DEL key [key ...]
+func (c *conn) del(args []string) {
+	t := c.table()
+	var n int64
+	for _, key := range args[1:] {
+		_, _, live := peek(t, key)
+		if _, err := t.Delete(key); err == nil && live {
+			n++
+		}
+	}
+	c.writeInt(n)
+}
+
+// EXISTS key [key ...] 重复的key重复计数
+func (c *conn) exists(args []string) {
+	t := c.table()
+	var n int64
+	for _, key := range args[1:] {
+		if _, _, ok := peek(t, key); ok {
+			n++
+		}
+	}
+	c.writeInt(n)
+}
+
+// TTL key / PTTL key：key不存在返回-2 没有设置有效期返回-1
+func (c *conn) ttl(args []string) {
+	_, ttl, ok := peek(c.table(), args[1])
+	switch {
+	case !ok:
+		c.writeInt(-2)
+	case ttl == 0:
+		c.writeInt(-1)
+	case strings.EqualFold(args[0], "PTTL"):
+		c.writeInt(int64((ttl + time.Millisecond/2) / time.Millisecond))
+	default:
+		c.writeInt(int64((ttl + time.Second/2) / time.Second))
+	}
+}
+
+// EXPIRE key seconds：seconds <= 0时删除key 有效期自EXPIRE时起计算
+// 注：item的lifeSpan不可修改 使用新的lifeSpan重新添加
+func (c *conn) expire(args []string) {
+	seconds, ok := parseInt(c, args[2])
+	if !ok {
+		return
+	}
+	if seconds > math.MaxInt64/int64(time.Second) {
+		c.writeError("ERR invalid expire time in 'expire' command")
+		return
+	}
+
+	t := c.table()
+	item, _, ok := peek(t, args[1])
+	if !ok {
+		c.writeInt(0)
+		return
+	}
+	data := item.Data()
+	if v, ok := data.(*expiring); ok {
+		data = v.data
+	}
+	if seconds <= 0 {
+		t.Delete(args[1])
+	} else {
+		addExpiring(t, args[1], time.Duration(seconds)*time.Second, data, false)
+	}
+	c.writeInt(1)
+}
+
+// ========================================DB=====================================
+
+// DBSIZE 包括其他类型的key
+func (c *conn) dbsize(args []string) {
+	c.writeInt(int64(c.table().Count()))
+}
+
+// FLUSHDB [ASYNC | SYNC]
+func (c *conn) flushdb(args []string) {
+	if len(args) > 2 || (len(args) == 2 && !strings.EqualFold(args[1], "ASYNC") && !strings.EqualFold(args[1], "SYNC")) {
+		c.writeError("ERR syntax error")
+		return
+	}
+	c.table().Flush()
+	c.writeSimple("OK")
+}
+
+// 所有未过期的string key
+func liveKeys(t *cache_go.CacheTable) (keys []string) {
+	now := time.Now()
+	t.Foreach(func(key interface{}, item *cache_go.CacheItem) {
+		k, ok := key.(string)
+		if !ok {
+			return
+		}
+		if ttl, expires := remaining(item, now); expires && ttl <= 0 {
+			return
+		}
+		keys = append(keys, k)
+	})
+	return keys
+}
+
+// KEYS pattern 按key排序
+func (c *conn) keys(args []string) {
+	keys := []string{}
+	for _, key := range liveKeys(c.table()) {
+		if match(args[1], key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	c.writeBulks(keys)
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+// table基于map 没有稳定的遍历顺序：按key的hash排序 cursor为下一个hash
+// 与redis相同 扫描期间一直存在的key至少被返回一次
+func (c *conn) scan(args []string) {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		c.writeError("ERR invalid cursor")
+		return
+	}
+	pattern, count, onlyStrings := "*", 10, true
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			c.writeError("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			n, ok := parseInt(c, args[i+1])
+			if !ok {
+				return
+			}
+			if n < 1 {
+				c.writeError("ERR syntax error")
+				return
+			}
+			count = int(min(n, math.MaxInt32))
+		case "TYPE":
+			onlyStrings = strings.EqualFold(args[i+1], "string") // 只有string类型
+		default:
+			c.writeError("ERR syntax error")
+			return
+		}
+	}
+
+	type entry struct {
+		hash uint64
+		key  string
+	}
+	var entries []entry
+	for _, key := range liveKeys(c.table()) {
+		if h := scanHash(key); h >= cursor && onlyStrings && match(pattern, key) {
+			entries = append(entries, entry{h, key})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].hash != entries[j].hash {
+			return entries[i].hash < entries[j].hash
+		}
+		return entries[i].key < entries[j].key
+	})
+
+	n := min(count, len(entries))
+	for n > 0 && n < len(entries) && entries[n].hash == entries[n-1].hash { // hash相同的key在同一页返回
+		n++
+	}
+	next := uint64(0)
+	if n < len(entries) {
+		next = entries[n-1].hash + 1
+	}
+
+	c.writeArrayHeader(2)
+	c.writeBulk(strconv.FormatUint(next, 10))
+	c.writeArrayHeader(n)
+	for _, e := range entries[:n] {
+		c.writeBulk(e.key)
+	}
+}
+
+// SCAN排序使用的hash 测试中可替换以构造相同的hash
+var scanHash = hashKey
+
+// FNV-1a 64
+func hashKey(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// INFO [section ...]
+func (c *conn) info(args []string) {
+	sections := map[string]bool{}
+	for _, arg := range args[1:] {
+		sections[strings.ToLower(arg)] = true
+	}
+	all := len(sections) == 0 || sections["all"] || sections["default"] || sections["everything"]
+
+	var sb strings.Builder
+	write := func(section string, lines ...string) {
+		if !all && !sections[strings.ToLower(section)] {
+			return
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\r\n")
+		}
+		sb.WriteString("# " + section + "\r\n")
+		for _, line := range lines {
+			sb.WriteString(line + "\r\n")
+		}
+	}
+
+	uptime := time.Since(c.s.started)
+	write("Server",
+		"redis_version:"+Version,
+		"redis_mode:standalone",
+		"process_id:"+strconv.Itoa(os.Getpid()),
+		"uptime_in_seconds:"+strconv.FormatInt(int64(uptime/time.Second), 10),
+		"uptime_in_days:"+strconv.FormatInt(int64(uptime/(24*time.Hour)), 10),
+	)
+	write("Clients",
+		"connected_clients:"+strconv.Itoa(c.s.Conns()),
+		"maxclients:"+strconv.Itoa(c.s.opts.MaxConns),
+	)
+	write("Stats",
+		"total_connections_received:"+strconv.FormatInt(c.s.srv.TotalConns(), 10),
+		"total_commands_processed:"+strconv.FormatInt(c.s.stats.commands.Load(), 10),
+		"rejected_connections:"+strconv.FormatInt(c.s.srv.RejectedConns(), 10),
+		"keyspace_hits:"+strconv.FormatInt(c.s.stats.hits.Load(), 10),
+		"keyspace_misses:"+strconv.FormatInt(c.s.stats.misses.Load(), 10),
+	)
+	var keyspace []string
+	for db := 0; db < c.s.opts.Databases; db++ {
+		var keys, expires int
+		now := time.Now()
+		c.s.Table(db).Foreach(func(key interface{}, item *cache_go.CacheItem) {
+			keys++
+			if _, ok := remaining(item, now); ok {
+				expires++
+			}
+		})
+		if keys > 0 {
+			keyspace = append(keyspace, fmt.Sprintf("db%d:keys=%d,expires=%d,avg_ttl=0", db, keys, expires))
+		}
+	}
+	write("Keyspace", keyspace...)
+
+	c.writeBulk(sb.String())
+}
+
+// ========================================glob=====================================
+
+// redis风格的glob匹配：* ? [abc] [^a] [a-z] 以及\转义
+func match(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if match(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			p := pattern[1:]
+			not := len(p) > 0 && p[0] == '^'
+			if not {
+				p = p[1:]
+			}
+			matched := false
+			for len(p) > 0 && p[0] != ']' {
+				switch {
+				case p[0] == '\\' && len(p) >= 2:
+					matched = matched || p[1] == s[0]
+					p = p[2:]
+				case len(p) >= 3 && p[1] == '-' && p[2] != ']':
+					lo, hi := min(p[0], p[2]), max(p[0], p[2])
+					matched = matched || (lo <= s[0] && s[0] <= hi)
+					p = p[3:]
+				default:
+					matched = matched || p[0] == s[0]
+					p = p[1:]
+				}
+			}
+			if matched == not {
+				return false
+			}
+			pattern = p // 停在]处(缺少]时为空)
+			if len(pattern) == 0 {
+				return len(s) == 1
+			}
+
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
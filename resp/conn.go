@@ -0,0 +1,153 @@
+package resp
+
+import (
+	"bufio"
+	"code-utils-demos/common/netserver"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 协议错误：返回错误后关闭连接
+type protocolError string
+
+func (e protocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+type conn struct {
+	s  *Server
+	nc *netserver.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+
+	db int // SELECT选择的DB
+}
+
+// 连接由netserver关闭
+func (c *conn) serve() {
+	for c.nc.Next(c.r, c.w) {
+		args, err := c.readCommand()
+		if err != nil {
+			var pe protocolError
+			if errors.As(err, &pe) {
+				c.writeError("ERR " + pe.Error())
+				c.w.Flush()
+			} else {
+				c.nc.LogError(err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if quit := c.dispatch(args); quit {
+			c.w.Flush()
+			return
+		}
+	}
+}
+
+// ========================================读取命令=====================================
+
+// 读取一条命令：多行的数组(*N\r\n$len\r\n...) 或者以空格分隔的单行(inline 用于telnet等)
+func (c *conn) readCommand() ([]string, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArgs {
+		return nil, protocolError("invalid multibulk length")
+	}
+	args := make([]string, 0, min(max(n, 0), 64)) // n来自客户端 不预先分配过多
+	for i := 0; i < n; i++ {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, protocolError("expected '$', got '" + line[:min(len(line), 1)] + "'")
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > c.s.opts.MaxBulkSize {
+			return nil, protocolError("invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, protocolError("expected CRLF after bulk string")
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// 读取一行 去掉结尾的\r\n
+func (c *conn) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", protocolError("too big inline request")
+	}
+	if err != nil {
+		return "", err
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return string(line), nil
+}
+
+// ========================================响应=====================================
+
+func (c *conn) writeSimple(s string) {
+	c.w.WriteByte('+')
+	c.w.WriteString(s)
+	c.w.WriteString("\r\n")
+}
+
+// s以错误类型开头 如"ERR ..."、"WRONGTYPE ..."
+func (c *conn) writeError(s string) {
+	c.w.WriteByte('-')
+	c.w.WriteString(s)
+	c.w.WriteString("\r\n")
+}
+
+func (c *conn) writeInt(n int64) {
+	c.w.WriteByte(':')
+	c.w.WriteString(strconv.FormatInt(n, 10))
+	c.w.WriteString("\r\n")
+}
+
+func (c *conn) writeBulk(s string) {
+	c.w.WriteByte('$')
+	c.w.WriteString(strconv.Itoa(len(s)))
+	c.w.WriteString("\r\n")
+	c.w.WriteString(s)
+	c.w.WriteString("\r\n")
+}
+
+func (c *conn) writeNil() {
+	c.w.WriteString("$-1\r\n")
+}
+
+func (c *conn) writeArrayHeader(n int) {
+	c.w.WriteByte('*')
+	c.w.WriteString(strconv.Itoa(n))
+	c.w.WriteString("\r\n")
+}
+
+func (c *conn) writeBulks(a []string) {
+	c.writeArrayHeader(len(a))
+	for _, s := range a {
+		c.writeBulk(s)
+	}
+}
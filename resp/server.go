@@ -0,0 +1,135 @@
+package resp
+
+import (
+	"bufio"
+	cache_go "code-utils-demos/cachev2.0"
+	"code-utils-demos/common/netserver"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RESP2(Redis协议)的服务端：每个逻辑DB对应一个cache_go.Cache(name)的table
+// 支持的命令：GET SET(EX/PX/NX) DEL EXISTS TTL PTTL EXPIRE DBSIZE FLUSHDB KEYS SCAN INFO SELECT PING ECHO QUIT COMMAND
+// See https://redis.io/docs/latest/develop/reference/protocol-spec/
+//
+// key和value均以string保存在table中 其他类型的key对RESP不可见；其他类型的value由GET按fmt.Sprint返回
+// EX/PX、EXPIRE的有效期与redis相同 自设置时起计算：value与过期时间一起保存 GET、TTL、EXISTS均不会延长有效期
+// item的lifeSpan设为相同的时长 只用于table的清理(cache_go的lifeSpan自最后一次访问起计算 清理可能晚于过期时间 期间key不可见)
+// 其他程序添加的item按cache_go的语义：有效期自最后一次访问起计算
+// EXPIRE重新添加item(access count等随之重置)
+
+// 服务端版本 INFO返回
+const Version = "7.0.0-cache_go"
+
+const (
+	DefaultDatabases    = 16
+	DefaultTablePrefix  = "db"
+	DefaultMaxBulkSize  = 64 << 20 // 单个参数的最大字节数
+	maxArgs             = 1 << 20  // 一条命令的最大参数个数
+	maxInlineLineLength = 64 << 10
+)
+
+// Shutdown、Close之后Serve返回ErrServerClosed
+var ErrServerClosed = errors.New("resp: server closed")
+
+type Options struct {
+	Databases   int           // 逻辑DB的个数 <= 0时使用DefaultDatabases
+	TablePrefix string        // DB n对应的table为TablePrefix+n 为空时使用DefaultTablePrefix
+	MaxConns    int           // 最大连接数 超过时返回错误并关闭连接 <= 0表示不限制
+	MaxBulkSize int           // 单个参数的最大字节数 <= 0时使用DefaultMaxBulkSize
+	IdleTimeout time.Duration // 连接空闲超时 0表示不限制
+	Logger      *log.Logger   // 记录连接错误 nil表示不记录
+}
+
+type Server struct {
+	opts Options
+
+	srv *netserver.Server // 监听及连接的管理
+
+	started time.Time
+	stats   counters
+}
+
+// 运行计数 均为原子操作
+type counters struct {
+	commands     atomic.Int64
+	hits, misses atomic.Int64
+}
+
+func NewServer(opts Options) *Server {
+	if opts.Databases <= 0 {
+		opts.Databases = DefaultDatabases
+	}
+	if opts.TablePrefix == "" {
+		opts.TablePrefix = DefaultTablePrefix
+	}
+	if opts.MaxBulkSize <= 0 {
+		opts.MaxBulkSize = DefaultMaxBulkSize
+	}
+	s := &Server{
+		opts:    opts,
+		started: time.Now(),
+	}
+	s.srv = netserver.New(netserver.Options{
+		Name:        "resp",
+		MaxConns:    opts.MaxConns,
+		Reject:      "-ERR max number of clients reached\r\n", // 与redis相同
+		IdleTimeout: opts.IdleTimeout,
+		Logger:      opts.Logger,
+		ErrClosed:   ErrServerClosed,
+		Handler:     s.serveConn,
+	})
+	return s
+}
+
+// DB db对应的table
+func (s *Server) Table(db int) *cache_go.CacheTable {
+	return cache_go.Cache(s.opts.TablePrefix + strconv.Itoa(db))
+}
+
+// 监听addr并处理连接
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// 处理l上的连接 直至l出错或服务端被关闭 返回时l已被关闭
+func (s *Server) Serve(l net.Listener) error {
+	return s.srv.Serve(l)
+}
+
+// 优雅关闭：停止接受新连接 关闭空闲的连接 等待正在执行的命令结束
+// ctx结束时强制关闭剩余的连接并返回ctx.Err()
+// 注：不清空table
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// 立即关闭所有监听及连接
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// 当前的连接数
+func (s *Server) Conns() int {
+	return s.srv.Conns()
+}
+
+// 处理一个连接 见netserver.Options.Handler
+func (s *Server) serveConn(nc *netserver.Conn) {
+	c := &conn{
+		s:  s,
+		nc: nc,
+		r:  bufio.NewReaderSize(nc, maxInlineLineLength),
+		w:  bufio.NewWriter(nc),
+	}
+	c.serve()
+}
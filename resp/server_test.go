@@ -0,0 +1,382 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 在127.0.0.1:0上启动服务端 每个测试使用独立的table
+func startServer(t *testing.T, opts Options) (*Server, string) {
+	t.Helper()
+	opts.TablePrefix = t.Name() + ":"
+	opts.Databases = 2
+	s := NewServer(opts)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() {
+		s.Close()
+		for db := 0; db < opts.Databases; db++ {
+			s.Table(db).Flush()
+		}
+	})
+	return s, l.Addr().String()
+}
+
+type client struct {
+	t  *testing.T
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *client {
+	t.Helper()
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { nc.Close() })
+	return &client{t: t, nc: nc, r: bufio.NewReader(nc)}
+}
+
+// 错误响应
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+
+// 读取一个响应：简单字符串和bulk为string nil bulk为nil 整数为int64 错误为replyError 数组为[]interface{}
+func (c *client) read() interface{} {
+	c.t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return replyError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			c.t.Fatalf("bad integer reply %q", line)
+		}
+		return n
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			c.t.Fatalf("read bulk: %v", err)
+		}
+		return string(buf[:n])
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		a := make([]interface{}, n)
+		for i := range a {
+			a[i] = c.read()
+		}
+		return a
+	}
+	c.t.Fatalf("bad reply %q", line)
+	return nil
+}
+
+// 以multibulk发送命令 返回响应
+func (c *client) do(args ...string) interface{} {
+	c.t.Helper()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	c.raw(sb.String())
+	return c.read()
+}
+
+func (c *client) raw(s string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.nc, s); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) expect(want interface{}, args ...string) {
+	c.t.Helper()
+	if got := c.do(args...); !reflect.DeepEqual(got, want) {
+		c.t.Fatalf("%q = %#v, want %#v", args, got, want)
+	}
+}
+
+func (c *client) expectEOF() {
+	c.t.Helper()
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		c.t.Fatalf("read: %v, want EOF", err)
+	}
+}
+
+func TestParseCommands(t *testing.T) {
+	_, addr := startServer(t, Options{})
+
+	c := dial(t, addr)
+	c.raw("*2\r\n$4\r\nECHO\r\n$5\r\nhe\r\nl\r\n") // bulk中可以包含CRLF
+	if got := c.read(); got != "he\r\nl" {
+		t.Errorf("multibulk ECHO = %q", got)
+	}
+	c.raw("PING\r\necho  inline  \n") // inline：空白分隔 可以只有\n
+	if got := c.read(); got != "PONG" {
+		t.Errorf("inline PING = %q", got)
+	}
+	if got := c.read(); got != "inline" {
+		t.Errorf("inline ECHO = %q", got)
+	}
+	c.raw("*2\r\n$4\r\nPING\r\n$0\r\n\r\n") // 空参数
+	if got := c.read(); got != "" {
+		t.Errorf("PING with empty bulk = %q", got)
+	}
+	c.raw("\r\n") // 空行被忽略
+	c.expect("PONG", "PING")
+	c.expect(replyError("ERR unknown command 'NOPE', with args beginning with: 'a' "), "NOPE", "a")
+	c.expect(replyError("ERR wrong number of arguments for 'get' command"), "GET")
+
+	// 协议错误：返回错误后关闭连接
+	for name, tc := range map[string]struct{ input, want string }{
+		"bad multibulk length": {"*x\r\n", "ERR Protocol error: invalid multibulk length"},
+		"bad bulk length":      {"*1\r\n$x\r\n", "ERR Protocol error: invalid bulk length"},
+		"negative bulk length": {"*1\r\n$-1\r\n", "ERR Protocol error: invalid bulk length"},
+		"missing $":            {"*1\r\n+PING\r\n", "ERR Protocol error: expected '$', got '+'"},
+		"missing CRLF":         {"*1\r\n$4\r\nPINGxx", "ERR Protocol error: expected CRLF after bulk string"},
+	} {
+		c := dial(t, addr)
+		c.raw(tc.input)
+		if got := c.read(); got != replyError(tc.want) {
+			t.Errorf("%s: reply = %#v, want %q", name, got, tc.want)
+		}
+		c.expectEOF()
+	}
+}
+
+func TestSetOptionsAndTTL(t *testing.T) {
+	_, addr := startServer(t, Options{})
+	c := dial(t, addr)
+
+	c.expect("OK", "SET", "k", "v")
+	c.expect("v", "GET", "k")
+	c.expect(int64(-1), "TTL", "k")
+	c.expect(int64(-2), "TTL", "missing")
+	c.expect(int64(-2), "PTTL", "missing")
+
+	c.expect(nil, "SET", "k", "other", "NX")
+	c.expect("v", "GET", "k")
+	c.expect("OK", "set", "n", "v", "nx")
+
+	c.expect("OK", "SET", "k", "v", "EX", "100")
+	c.expect(int64(100), "TTL", "k")
+	c.expect("OK", "SET", "k", "v", "PX", "5000")
+	if ms := c.do("PTTL", "k").(int64); ms <= 4000 || ms > 5000 {
+		t.Errorf("PTTL after PX 5000 = %d", ms)
+	}
+	c.expect(int64(5), "TTL", "k")
+
+	for _, args := range [][]string{
+		{"SET", "k", "v", "EX", "0"},
+		{"SET", "k", "v", "PX", "-1"},
+	} {
+		c.expect(replyError("ERR invalid expire time in 'set' command"), args...)
+	}
+	c.expect(replyError("ERR value is not an integer or out of range"), "SET", "k", "v", "EX", "x")
+	for _, args := range [][]string{
+		{"SET", "k", "v", "EX"},
+		{"SET", "k", "v", "EX", "1", "PX", "1"},
+		{"SET", "k", "v", "XX"},
+		{"SET", "k", "v", "NX", "NX"},
+	} {
+		c.expect(replyError("ERR syntax error"), args...)
+	}
+
+	// 过期后：GET未命中 TTL返回-2 NX可以写入
+	c.expect("OK", "SET", "e", "v", "PX", "30")
+	time.Sleep(60 * time.Millisecond)
+	c.expect(nil, "GET", "e")
+	c.expect(int64(-2), "TTL", "e")
+	c.expect(int64(0), "EXISTS", "e")
+	c.expect("OK", "SET", "e", "v2", "NX")
+	c.expect("v2", "GET", "e")
+}
+
+// 有效期自SET起计算：GET不会延长
+func TestExpiryNotExtendedByGet(t *testing.T) {
+	_, addr := startServer(t, Options{})
+	c := dial(t, addr)
+
+	c.expect("OK", "SET", "k", "v", "PX", "100")
+	c.expect("OK", "SET", "other", "v")
+	time.Sleep(60 * time.Millisecond)
+	c.expect("v", "GET", "k")
+	if ms := c.do("PTTL", "k").(int64); ms <= 0 || ms > 40 {
+		t.Errorf("PTTL after GET = %d, want <= 40", ms)
+	}
+	time.Sleep(60 * time.Millisecond)
+	c.expect(nil, "GET", "k")
+	c.expect(int64(-2), "PTTL", "k")
+	c.expect([]interface{}{"other"}, "KEYS", "*")
+	c.expect(int64(1), "DBSIZE") // GET删除了已过期的key
+
+	// EXPIRE同样自设置时起计算
+	c.expect(int64(1), "EXPIRE", "other", "100")
+	c.expect("v", "GET", "other")
+	c.expect(int64(100), "TTL", "other")
+	if info := c.do("INFO", "keyspace").(string); !strings.Contains(info, "db0:keys=1,expires=1,") {
+		t.Errorf("INFO keyspace = %q", info)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	_, addr := startServer(t, Options{})
+	c := dial(t, addr)
+
+	c.expect("OK", "SET", "k", "v")
+	c.expect(int64(1), "EXPIRE", "k", "100")
+	c.expect(int64(100), "TTL", "k")
+	c.expect("v", "GET", "k")
+	c.expect(int64(0), "EXPIRE", "missing", "100")
+
+	c.expect(int64(1), "EXPIRE", "k", "0") // <= 0时删除
+	c.expect(int64(0), "EXISTS", "k")
+	c.expect("OK", "SET", "k", "v")
+	c.expect(int64(1), "EXPIRE", "k", "-5")
+	c.expect(nil, "GET", "k")
+	c.expect(int64(0), "EXPIRE", "k", "-5")
+}
+
+func TestKeysAndScan(t *testing.T) {
+	_, addr := startServer(t, Options{})
+	c := dial(t, addr)
+
+	want := map[string]bool{}
+	for i := 0; i < 25; i++ {
+		key := "key:" + strconv.Itoa(i)
+		want[key] = true
+		c.expect("OK", "SET", key, "v")
+	}
+	c.expect("OK", "SET", "other", "v")
+	c.expect([]interface{}{"key:20", "key:21", "key:22", "key:23", "key:24"}, "KEYS", "key:2?")
+
+	seen := map[string]int{}
+	cursor := "0"
+	for pages := 0; ; pages++ {
+		reply := c.do("SCAN", cursor, "MATCH", "key:*", "COUNT", "4").([]interface{})
+		for _, key := range reply[1].([]interface{}) {
+			seen[key.(string)]++
+		}
+		if cursor = reply[0].(string); cursor == "0" {
+			break
+		}
+		if pages > 25 {
+			t.Fatal("SCAN did not terminate")
+		}
+	}
+	for key := range want {
+		if seen[key] != 1 {
+			t.Errorf("SCAN returned %q %d times", key, seen[key])
+		}
+	}
+	if len(seen) != len(want) {
+		t.Errorf("SCAN returned %d keys, want %d", len(seen), len(want))
+	}
+	c.expect([]interface{}{"0", []interface{}{}}, "SCAN", "0", "TYPE", "hash")
+}
+
+// hash相同的key在同一页返回 之后的cursor跳过它们
+func TestScanEqualHashes(t *testing.T) {
+	defer func(h func(string) uint64) { scanHash = h }(scanHash)
+	scanHash = func(key string) uint64 { return uint64(len(key)) }
+
+	_, addr := startServer(t, Options{})
+	c := dial(t, addr)
+	for _, key := range []string{"a", "b", "c", "dd", "ee", "fff"} {
+		c.expect("OK", "SET", key, "v")
+	}
+	c.expect([]interface{}{"2", []interface{}{"a", "b", "c"}}, "SCAN", "0", "COUNT", "1")
+	c.expect([]interface{}{"3", []interface{}{"dd", "ee"}}, "SCAN", "2", "COUNT", "2")
+	c.expect([]interface{}{"0", []interface{}{"fff"}}, "SCAN", "3", "COUNT", "2")
+}
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"*", "abc", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abcd", false},
+		{"a**c", "ac", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"[^a]", "", false},
+		{"[^a]", "ab", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[c-a]llo", "hbllo", true}, // 反向的范围
+		{"h[a-c]llo", "hdllo", false},
+		{"[a-]", "-", true}, // 结尾的-为普通字符
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`\?`, "?", true},
+		{`\`, `\`, true}, // 结尾的\为普通字符
+		{`[\]]`, "]", true},
+		{`[\^a]`, "^", true},
+		{"[abc", "a", true}, // 缺少]：匹配一个字符后结束
+		{"[abc", "ab", false},
+		{"x[ab", "xb", true},
+		{"[]", "a", false},
+	} {
+		if got := match(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestServerMaxConnsAndShutdown(t *testing.T) {
+	s, addr := startServer(t, Options{MaxConns: 1})
+	c1 := dial(t, addr)
+	c1.expect("PONG", "PING")
+
+	c2 := dial(t, addr)
+	if got := c2.read(); got != replyError("ERR max number of clients reached") {
+		t.Fatalf("rejected conn got %#v", got)
+	}
+	c2.expectEOF()
+
+	c1.expect("OK", "SELECT", "1")
+	if err := s.Shutdown(context.Background()); err != nil { // c1空闲：立即关闭
+		t.Fatal(err)
+	}
+	c1.expectEOF()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(l); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve after Shutdown = %v, want ErrServerClosed", err)
+	}
+}